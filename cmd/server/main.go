@@ -16,8 +16,11 @@ import (
 	"masquerade-dns/internal/pkg/logger"
 	"masquerade-dns/internal/services/dnslimiter"
 	"masquerade-dns/internal/services/dnsresolver"
+	"masquerade-dns/internal/services/dnsrewriter"
+	"masquerade-dns/internal/services/dnsrrl"
 	"masquerade-dns/internal/services/dnsserver"
 	"masquerade-dns/internal/services/dnsswitcher"
+	"masquerade-dns/internal/services/dnsview"
 	"masquerade-dns/internal/services/httpserver"
 )
 
@@ -69,23 +72,65 @@ func main() {
 	httpServer := httpserver.NewService(&cfg.HTTPServer, logger)
 	httpServer.Start()
 
-	dnsLimiter := dnslimiter.NewService(&cfg.DNSLimiter)
-	dnsResolver := dnsresolver.NewService(&cfg.DNSResolver, metrics, logger)
+	dnsLimiter, err := dnslimiter.NewService(&cfg.DNSLimiter, logger)
+	if err != nil {
+		logger.Fatalw("Can't create DNS limiter", zap.Error(err))
+	}
+
+	dnsLimiter.Start()
+
+	dnsResolver, err := dnsresolver.NewService(&cfg.DNSResolver, metrics, logger)
+	if err != nil {
+		logger.Fatalw("Can't create DNS resolver", zap.Error(err))
+	}
 
-	dnsSwitcher := dnsswitcher.NewService(
+	dnsRewriter, err := dnsrewriter.NewService(&cfg.DNSRewriter, logger)
+	if err != nil {
+		logger.Fatalw("Can't create DNS rewriter", zap.Error(err))
+	}
+
+	dnsSwitcher, err := dnsswitcher.NewService(
 		&cfg.DNSSwitcher,
 		metrics,
 		dnsLimiter,
+		dnsResolver,
 		logger,
 	)
+	if err != nil {
+		logger.Fatalw("Can't create DNS switcher", zap.Error(err))
+	}
 
-	dnsServer := dnsserver.NewService(
-		&cfg.DNSServer,
+	dnsViews, err := dnsview.NewService(
+		&cfg.DNSViews,
+		&cfg.DNSSwitcher,
 		metrics,
+		dnsLimiter,
 		dnsResolver,
 		dnsSwitcher,
 		logger,
 	)
+	if err != nil {
+		logger.Fatalw("Can't create DNS views", zap.Error(err))
+	}
+
+	dnsRRL, err := dnsrrl.NewService(&cfg.DNSRRL)
+	if err != nil {
+		logger.Fatalw("Can't create DNS RRL", zap.Error(err))
+	}
+
+	dnsRRL.Start()
+
+	dnsServer, err := dnsserver.NewService(
+		&cfg.DNSServer,
+		metrics,
+		dnsViews,
+		dnsRewriter,
+		dnsRRL,
+		logger,
+	)
+	if err != nil {
+		logger.Fatalw("Can't create DNS server", zap.Error(err))
+	}
 
 	dnsServer.Start()
 
@@ -97,6 +142,9 @@ func main() {
 		logger.Errorw("Can't stop DNS server", zap.Error(err))
 	}
 
+	dnsRRL.Shutdown()
+	dnsLimiter.Shutdown()
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer shutdownCancel()
 
@@ -7,8 +7,11 @@ import (
 	"masquerade-dns/internal/pkg/logger"
 	"masquerade-dns/internal/services/dnslimiter"
 	"masquerade-dns/internal/services/dnsresolver"
+	"masquerade-dns/internal/services/dnsrewriter"
+	"masquerade-dns/internal/services/dnsrrl"
 	"masquerade-dns/internal/services/dnsserver"
 	"masquerade-dns/internal/services/dnsswitcher"
+	"masquerade-dns/internal/services/dnsview"
 	"masquerade-dns/internal/services/httpserver"
 )
 
@@ -19,6 +22,9 @@ type Config struct {
 	DNSSwitcher dnsswitcher.Config `yaml:"switcher"`
 	DNSLimiter  dnslimiter.Config  `yaml:"limiter"`
 	DNSResolver dnsresolver.Config `yaml:"resolver"`
+	DNSRewriter dnsrewriter.Config `yaml:"rewriter"`
+	DNSViews    dnsview.Config     `yaml:"views"`
+	DNSRRL      dnsrrl.Config      `yaml:"rrl"`
 }
 
 func ParseFile(path string) (*Config, error) {
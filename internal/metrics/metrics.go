@@ -12,15 +12,28 @@ const (
 	StatusFailed  = "failed"
 )
 
+const (
+	RRLDrop = "drop"
+	RRLSlip = "slip"
+)
+
 const namespace = "masquerade"
 
 type Metrics struct {
 	totalDNSRequests    *prometheus.CounterVec
 	resolvedDNSRequests *prometheus.CounterVec
 	switchedDNSRequests *prometheus.CounterVec
+	blockedDNSRequests  *prometheus.CounterVec
 
 	limitedDNSRequests prometheus.Counter
 
+	rateLimitedDNSResponses *prometheus.CounterVec
+
+	switchRuleHits         *prometheus.CounterVec
+	switchRuleLimits       *prometheus.CounterVec
+	switchRuleFallthroughs *prometheus.CounterVec
+	switchRuleLastHit      *prometheus.GaugeVec
+
 	durationDNSRequests prometheus.Histogram
 }
 
@@ -60,6 +73,15 @@ func (m *Metrics) init() {
 		[]string{"remote_ip"},
 	)
 
+	m.blockedDNSRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "dns_requests_blocked_total",
+			Help:      "Total number of blocked DNS requests.",
+			Namespace: namespace,
+		},
+		[]string{"list"},
+	)
+
 	m.limitedDNSRequests = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name:      "dns_requests_limited_total",
@@ -68,6 +90,51 @@ func (m *Metrics) init() {
 		},
 	)
 
+	m.rateLimitedDNSResponses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "dns_responses_rate_limited_total",
+			Help:      "Total number of DNS responses dropped or slipped by response rate limiting.",
+			Namespace: namespace,
+		},
+		[]string{"action"},
+	)
+
+	m.switchRuleHits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "switch_rule_hits_total",
+			Help:      "Total number of DNS requests answered by switch rule.",
+			Namespace: namespace,
+		},
+		[]string{"rule"},
+	)
+
+	m.switchRuleLimits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "switch_rule_limits_total",
+			Help:      "Total number of DNS requests limited by switch rule.",
+			Namespace: namespace,
+		},
+		[]string{"rule"},
+	)
+
+	m.switchRuleFallthroughs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:      "switch_rule_fallthroughs_total",
+			Help:      "Total number of DNS requests fallen through by switch rule.",
+			Namespace: namespace,
+		},
+		[]string{"rule"},
+	)
+
+	m.switchRuleLastHit = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:      "switch_rule_last_hit_timestamp_seconds",
+			Help:      "Timestamp of the last DNS request answered by switch rule.",
+			Namespace: namespace,
+		},
+		[]string{"rule"},
+	)
+
 	m.durationDNSRequests = promauto.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:      "dns_requests_duration",
@@ -89,10 +156,37 @@ func (m *Metrics) IncSwitchedDNSRequests(addr net.IP) {
 	m.switchedDNSRequests.WithLabelValues(addr.String()).Inc()
 }
 
+func (m *Metrics) IncBlockedDNSRequests(list string) {
+	m.blockedDNSRequests.WithLabelValues(list).Inc()
+}
+
 func (m *Metrics) IncLimitedDNSRequests() {
 	m.limitedDNSRequests.Inc()
 }
 
+func (m *Metrics) IncRateLimitedDNSResponses(action string) {
+	m.rateLimitedDNSResponses.WithLabelValues(action).Inc()
+}
+
+func (m *Metrics) InitSwitchRule(rule string) {
+	m.switchRuleHits.WithLabelValues(rule)
+	m.switchRuleLimits.WithLabelValues(rule)
+	m.switchRuleFallthroughs.WithLabelValues(rule)
+}
+
+func (m *Metrics) IncSwitchRuleHits(rule string) {
+	m.switchRuleHits.WithLabelValues(rule).Inc()
+	m.switchRuleLastHit.WithLabelValues(rule).SetToCurrentTime()
+}
+
+func (m *Metrics) IncSwitchRuleLimits(rule string) {
+	m.switchRuleLimits.WithLabelValues(rule).Inc()
+}
+
+func (m *Metrics) IncSwitchRuleFallthroughs(rule string) {
+	m.switchRuleFallthroughs.WithLabelValues(rule).Inc()
+}
+
 func (m *Metrics) NewDNSRequestsTimer() *prometheus.Timer {
 	return prometheus.NewTimer(m.durationDNSRequests)
 }
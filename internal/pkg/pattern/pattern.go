@@ -0,0 +1,77 @@
+package pattern
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	WildcardPrefix = "*."
+	SuffixPrefix   = "."
+	RegexpMarker   = "/"
+)
+
+type Kind int
+
+const (
+	KindExact Kind = iota
+	KindWildcard
+	KindSuffix
+	KindRegexp
+)
+
+type Pattern struct {
+	Kind   Kind
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+func Parse(source string) (*Pattern, error) {
+	switch {
+	case source == "":
+		return nil, errors.New("source is not set")
+
+	case len(source) > 1 && strings.HasPrefix(source, RegexpMarker) && strings.HasSuffix(source, RegexpMarker):
+		r, err := regexp.Compile(source[len(RegexpMarker) : len(source)-len(RegexpMarker)])
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't compile regular expression %q", source)
+		}
+
+		return &Pattern{Kind: KindRegexp, Regexp: r}, nil
+
+	case strings.HasPrefix(source, WildcardPrefix):
+		return &Pattern{Kind: KindWildcard, Name: CanonicalName(source[len(WildcardPrefix):])}, nil
+
+	case len(source) > 1 && strings.HasPrefix(source, SuffixPrefix):
+		return &Pattern{Kind: KindSuffix, Name: CanonicalName(source[len(SuffixPrefix):])}, nil
+
+	default:
+		return &Pattern{Kind: KindExact, Name: CanonicalName(source)}, nil
+	}
+}
+
+func (p *Pattern) Match(name string) bool {
+	if p.Kind == KindRegexp {
+		return p.Regexp.MatchString(name)
+	}
+
+	name = CanonicalName(name)
+
+	switch p.Kind {
+	case KindWildcard:
+		return name != p.Name && dns.IsSubDomain(p.Name, name)
+
+	case KindSuffix:
+		return dns.IsSubDomain(p.Name, name)
+
+	default:
+		return name == p.Name
+	}
+}
+
+func CanonicalName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
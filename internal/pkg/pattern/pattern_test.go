@@ -0,0 +1,74 @@
+package pattern
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		source  string
+		kind    Kind
+		name    string
+		wantErr bool
+	}{
+		{source: "Example.Test", kind: KindExact, name: "example.test."},
+		{source: "*.example.test", kind: KindWildcard, name: "example.test."},
+		{source: ".example.test", kind: KindSuffix, name: "example.test."},
+		{source: "/^a.*$/", kind: KindRegexp},
+		{source: "/", kind: KindExact, name: "/."},
+		{source: "", wantErr: true},
+		{source: "/(/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			p, err := Parse(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if p.Kind != tt.kind || p.Name != tt.name {
+				t.Errorf("Parse() = {%v %q}, want {%v %q}", p.Kind, p.Name, tt.kind, tt.name)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		source string
+		name   string
+		want   bool
+	}{
+		{"example.test", "example.test.", true},
+		{"example.test", "EXAMPLE.test", true},
+		{"example.test", "a.example.test.", false},
+		{"*.example.test", "a.example.test.", true},
+		{"*.example.test", "a.b.example.test.", true},
+		{"*.example.test", "example.test.", false},
+		{"*.example.test", "aexample.test.", false},
+		{".example.test", "example.test.", true},
+		{".example.test", "a.example.test.", true},
+		{".example.test", "aexample.test.", false},
+		{`/^a-\d+\.example\.test\.$/`, "a-1.example.test.", true},
+		{`/^a-\d+\.example\.test\.$/`, "a-x.example.test.", false},
+		{`/a\//`, "a/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source+" "+tt.name, func(t *testing.T) {
+			p, err := Parse(tt.source)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if got := p.Match(tt.name); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package subnet
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxIPv4Bits = 32
+	maxIPv6Bits = 128
+)
+
+type Config struct {
+	IPv4 int `yaml:"ipv4"`
+	IPv6 int `yaml:"ipv6"`
+}
+
+func (c *Config) Validate() error {
+	if c.IPv4 < 0 || c.IPv4 > maxIPv4Bits {
+		return errors.Errorf("IPv4 prefix length %d is out of range", c.IPv4)
+	}
+
+	if c.IPv6 < 0 || c.IPv6 > maxIPv6Bits {
+		return errors.Errorf("IPv6 prefix length %d is out of range", c.IPv6)
+	}
+
+	return nil
+}
+
+func (c Config) Or(fallback Config) Config {
+	if c.IPv4 == 0 {
+		c.IPv4 = fallback.IPv4
+	}
+
+	if c.IPv6 == 0 {
+		c.IPv6 = fallback.IPv6
+	}
+
+	return c
+}
+
+func (c Config) Prefix(addr net.IP) netip.Prefix {
+	ip, ok := netip.AddrFromSlice(addr)
+	if !ok {
+		return netip.Prefix{}
+	}
+
+	ip = ip.Unmap()
+
+	bits := c.IPv6
+	if ip.Is4() {
+		bits = c.IPv4
+	}
+
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return netip.PrefixFrom(ip, ip.BitLen())
+	}
+
+	return prefix
+}
@@ -0,0 +1,48 @@
+package subnet
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPrefix(t *testing.T) {
+	config := Config{IPv4: 24, IPv6: 56}
+
+	tests := []struct {
+		addr net.IP
+		want string
+	}{
+		{net.ParseIP("192.0.2.77").To4(), "192.0.2.0/24"},
+		{net.ParseIP("192.0.2.77").To16(), "192.0.2.0/24"},
+		{net.ParseIP("2001:db8:1:2ff::1"), "2001:db8:1:200::/56"},
+		{nil, "invalid Prefix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := config.Prefix(tt.addr).String(); got != tt.want {
+				t.Errorf("Prefix(%s) = %s, want %s", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOr(t *testing.T) {
+	got := Config{IPv6: 64}.Or(Config{IPv4: 24, IPv6: 56})
+
+	if got.IPv4 != 24 || got.IPv6 != 64 {
+		t.Errorf("Or() = %+v, want {IPv4:24 IPv6:64}", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, config := range []Config{{IPv4: 33}, {IPv6: 129}, {IPv4: -1}} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate(%+v) error = nil", config)
+		}
+	}
+
+	if err := (&Config{IPv4: 32, IPv6: 128}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
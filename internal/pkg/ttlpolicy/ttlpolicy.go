@@ -0,0 +1,65 @@
+package ttlpolicy
+
+import (
+	"math/rand/v2"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	TTL    uint32 `yaml:"ttl"`
+	Min    uint32 `yaml:"min"`
+	Max    uint32 `yaml:"max"`
+	Jitter uint32 `yaml:"jitter"`
+}
+
+func (c *Config) Validate() error {
+	if c.Max != 0 && c.Min > c.Max {
+		return errors.Errorf("minimum TTL %d is greater than maximum TTL %d", c.Min, c.Max)
+	}
+
+	return nil
+}
+
+func (c *Config) Apply(sections ...[]dns.RR) {
+	if c == nil {
+		return
+	}
+
+	var jitter uint32
+
+	if c.Jitter != 0 {
+		jitter = rand.Uint32N(c.Jitter + 1)
+	}
+
+	for _, records := range sections {
+		for _, record := range records {
+			header := record.Header()
+
+			if header.Rrtype == dns.TypeOPT {
+				continue
+			}
+
+			header.Ttl = c.apply(header.Ttl, jitter)
+		}
+	}
+}
+
+func (c *Config) apply(ttl, jitter uint32) uint32 {
+	if c.TTL != 0 {
+		ttl = c.TTL
+	}
+
+	ttl -= min(ttl, jitter)
+
+	if c.Max != 0 && ttl > c.Max {
+		ttl = c.Max
+	}
+
+	if ttl < c.Min {
+		ttl = c.Min
+	}
+
+	return ttl
+}
@@ -0,0 +1,91 @@
+package dnslimiter
+
+import (
+	"slices"
+	"time"
+)
+
+type fixedWindow struct {
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func (w *fixedWindow) allow(_ time.Time, maxCount int) bool {
+	if w.count >= maxCount {
+		return false
+	}
+
+	w.count++
+
+	return true
+}
+
+func (w *fixedWindow) dump() entry {
+	return entry{Mode: modeFixedWindow, Start: w.start, Count: w.count}
+}
+
+func (w *fixedWindow) expired(now time.Time) bool {
+	return !now.Before(w.start.Add(w.window))
+}
+
+type slidingWindow struct {
+	window time.Duration
+	hits   []time.Time
+}
+
+func (w *slidingWindow) allow(now time.Time, maxCount int) bool {
+	threshold := now.Add(-w.window)
+
+	i := 0
+	for i < len(w.hits) && !w.hits[i].After(threshold) {
+		i++
+	}
+
+	w.hits = w.hits[i:]
+
+	if len(w.hits) >= maxCount {
+		return false
+	}
+
+	w.hits = append(w.hits, now)
+
+	return true
+}
+
+func (w *slidingWindow) dump() entry {
+	return entry{Mode: modeSlidingWindow, Hits: slices.Clone(w.hits)}
+}
+
+func (w *slidingWindow) expired(now time.Time) bool {
+	return len(w.hits) == 0 || !now.Before(w.hits[len(w.hits)-1].Add(w.window))
+}
+
+type tokenBucket struct {
+	rate     float64
+	tokens   float64
+	capacity float64
+	updated  time.Time
+}
+
+func (b *tokenBucket) allow(now time.Time, maxCount int) bool {
+	b.capacity = float64(maxCount)
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+func (b *tokenBucket) dump() entry {
+	return entry{Mode: modeTokenBucket, Start: b.updated, Tokens: b.tokens, Capacity: b.capacity}
+}
+
+func (b *tokenBucket) expired(now time.Time) bool {
+	return b.tokens+now.Sub(b.updated).Seconds()*b.rate >= b.capacity
+}
@@ -0,0 +1,188 @@
+package dnslimiter
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+type step struct {
+	offset  time.Duration
+	allow   bool
+	expired bool
+}
+
+func runSteps(t *testing.T, c counter, maxCount int, steps []step) {
+	t.Helper()
+
+	for i, s := range steps {
+		now := epoch.Add(s.offset)
+
+		if got := c.allow(now, maxCount); got != s.allow {
+			t.Errorf("step %d: allow() = %v, want %v", i, got, s.allow)
+		}
+
+		if got := c.expired(now); got != s.expired {
+			t.Errorf("step %d: expired() = %v, want %v", i, got, s.expired)
+		}
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxCount int
+		steps    []step
+	}{
+		{
+			name:     "limit within window",
+			maxCount: 2,
+			steps: []step{
+				{0, true, false},
+				{time.Second, true, false},
+				{2 * time.Second, false, false},
+				{9 * time.Second, false, false},
+			},
+		},
+		{
+			name:     "expire at window end",
+			maxCount: 1,
+			steps: []step{
+				{0, true, false},
+				{10 * time.Second, false, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, &fixedWindow{window: 10 * time.Second, start: epoch}, tt.maxCount, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxCount int
+		steps    []step
+	}{
+		{
+			name:     "limit within window",
+			maxCount: 2,
+			steps: []step{
+				{0, true, false},
+				{5 * time.Second, true, false},
+				{9 * time.Second, false, false},
+			},
+		},
+		{
+			name:     "slide past oldest hit",
+			maxCount: 2,
+			steps: []step{
+				{0, true, false},
+				{5 * time.Second, true, false},
+				{10 * time.Second, true, false},
+				{12 * time.Second, false, false},
+				{15 * time.Second, true, false},
+			},
+		},
+		{
+			name:     "expire after last hit",
+			maxCount: 1,
+			steps: []step{
+				{0, true, false},
+				{10 * time.Second, true, false},
+				{20 * time.Second, true, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, &slidingWindow{window: 10 * time.Second}, tt.maxCount, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindowExpired(t *testing.T) {
+	w := &slidingWindow{window: 10 * time.Second}
+
+	if !w.expired(epoch) {
+		t.Error("empty window is not expired")
+	}
+
+	w.allow(epoch, 1)
+
+	if w.expired(epoch.Add(9 * time.Second)) {
+		t.Error("window is expired before last hit ages out")
+	}
+
+	if !w.expired(epoch.Add(10 * time.Second)) {
+		t.Error("window is not expired after last hit ages out")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		maxCount int
+		steps    []step
+	}{
+		{
+			name:     "burst up to capacity",
+			rate:     1,
+			maxCount: 2,
+			steps: []step{
+				{0, true, false},
+				{0, true, false},
+				{0, false, false},
+			},
+		},
+		{
+			name:     "refill at rate",
+			rate:     1,
+			maxCount: 2,
+			steps: []step{
+				{0, true, false},
+				{0, true, false},
+				{500 * time.Millisecond, false, false},
+				{time.Second, true, false},
+				{time.Second, false, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &tokenBucket{
+				rate:     tt.rate,
+				tokens:   float64(tt.maxCount),
+				capacity: float64(tt.maxCount),
+				updated:  epoch,
+			}
+
+			runSteps(t, b, tt.maxCount, tt.steps)
+		})
+	}
+}
+
+func TestTokenBucketExpired(t *testing.T) {
+	b := &tokenBucket{rate: 2, tokens: 2, capacity: 2, updated: epoch}
+
+	if !b.expired(epoch) {
+		t.Error("full bucket is not expired")
+	}
+
+	b.allow(epoch, 2)
+
+	if b.expired(epoch.Add(400 * time.Millisecond)) {
+		t.Error("bucket is expired before refill")
+	}
+
+	if !b.expired(epoch.Add(500 * time.Millisecond)) {
+		t.Error("bucket is not expired after refill")
+	}
+}
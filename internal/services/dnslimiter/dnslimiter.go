@@ -2,74 +2,232 @@ package dnslimiter
 
 import (
 	"fmt"
+	"hash/fnv"
 	"net"
+	"net/netip"
+	"sync"
 	"time"
 
-	"github.com/dgraph-io/ristretto"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"masquerade-dns/internal/pkg/logger"
+	"masquerade-dns/internal/pkg/subnet"
+)
+
+const (
+	modeFixedWindow   = "fixed-window"
+	modeSlidingWindow = "sliding-window"
+	modeTokenBucket   = "token-bucket"
 )
 
+var defaultPrefix = subnet.Config{IPv4: 32, IPv6: 128}
+
 const (
-	cacheNumCounters = 1e6
-	cacheMaxCost     = 1 << 28
-	cacheBufferItems = 64
+	shardCount      = 64
+	cleanupInterval = time.Minute
 )
 
 type Config struct {
-	TTL time.Duration `env-required:"true" yaml:"ttl"`
+	TTL      time.Duration  `env-required:"true" yaml:"ttl"`
+	Mode     string         `yaml:"mode"`
+	Rate     float64        `yaml:"rate"`
+	Prefix   subnet.Config  `yaml:"prefix"`
+	Snapshot snapshotConfig `yaml:"snapshot"`
+}
+
+type counter interface {
+	allow(now time.Time, maxCount int) bool
+	expired(now time.Time) bool
+	dump() entry
+}
+
+type shard struct {
+	mu       sync.Mutex
+	counters map[string]counter
 }
 
 type Service struct {
 	config *Config
+	prefix subnet.Config
+	logger *zap.SugaredLogger
+
+	shards [shardCount]shard
 
-	cache *ristretto.Cache
+	done chan struct{}
+	wg   sync.WaitGroup
 }
 
-func NewService(config *Config) *Service {
-	cache, err := ristretto.NewCache(
-		&ristretto.Config{
-			NumCounters: cacheNumCounters,
-			MaxCost:     cacheMaxCost,
-			BufferItems: cacheBufferItems,
-		},
-	)
-	if err != nil {
-		panic(err)
+func NewService(config *Config, logger *zap.SugaredLogger) (*Service, error) {
+	switch config.Mode {
+	case "", modeFixedWindow, modeSlidingWindow, modeTokenBucket:
+	default:
+		return nil, errors.Errorf("limiter mode %q is not supported", config.Mode)
+	}
+
+	if config.TTL <= 0 {
+		return nil, errors.New("limiter TTL must be positive")
+	}
+
+	if config.Rate < 0 {
+		return nil, errors.New("limiter rate must not be negative")
+	}
+
+	if config.Snapshot.Interval < 0 {
+		return nil, errors.New("limiter snapshot interval must not be negative")
 	}
 
-	return &Service{
+	if err := config.Prefix.Validate(); err != nil {
+		return nil, errors.Wrap(err, "can't use limiter prefix length")
+	}
+
+	s := &Service{
 		config: config,
-		cache:  cache,
+		prefix: config.Prefix.Or(defaultPrefix),
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	for i := range s.shards {
+		s.shards[i].counters = make(map[string]counter)
 	}
+
+	return s, nil
 }
 
-func (s *Service) Limit(addr net.IP, source string, maxCount int) bool {
+func (s *Service) Start() {
+	if s.config.Snapshot.Path != "" {
+		restored, err := s.restore(time.Now())
+		if err != nil {
+			s.logger.Warnw("Can't restore limiter snapshot", logger.Error(err))
+		} else {
+			s.logger.Infow("Restore limiter snapshot", "counters", restored)
+		}
+	}
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		var snapshots <-chan time.Time
+
+		if s.config.Snapshot.Path != "" {
+			snapshotTicker := time.NewTicker(s.snapshotInterval())
+			defer snapshotTicker.Stop()
+
+			snapshots = snapshotTicker.C
+		}
+
+		for {
+			select {
+			case <-s.done:
+				return
+
+			case now := <-ticker.C:
+				s.cleanup(now)
+
+			case now := <-snapshots:
+				if err := s.save(now); err != nil {
+					s.logger.Errorw("Can't save limiter snapshot", logger.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) Shutdown() {
+	close(s.done)
+
+	s.wg.Wait()
+
+	if s.config.Snapshot.Path != "" {
+		if err := s.save(time.Now()); err != nil {
+			s.logger.Errorw("Can't save limiter snapshot", logger.Error(err))
+		}
+	}
+}
+
+func (s *Service) Limit(addr net.IP, source string, maxCount int, prefix subnet.Config) bool {
+	return s.limit(time.Now(), addr, source, maxCount, prefix)
+}
+
+func (s *Service) limit(now time.Time, addr net.IP, source string, maxCount int, prefix subnet.Config) bool {
 	if maxCount == 0 {
 		return false
 	}
 
-	key := makeKey(addr, source)
+	key := makeKey(prefix.Or(s.prefix).Prefix(addr), source)
 
-	value, ok := s.cache.Get(key)
-	if !ok {
-		s.cache.SetWithTTL(key, 0, 1, s.config.TTL)
+	shard := s.shard(key)
 
-		return false
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok := shard.counters[key]
+	if !ok || c.expired(now) {
+		c = s.newCounter(now, maxCount)
+		shard.counters[key] = c
 	}
 
-	count, ok := value.(int)
-	if !ok {
-		return false
+	return !c.allow(now, maxCount)
+}
+
+func (s *Service) mode() string {
+	if s.config.Mode == "" {
+		return modeFixedWindow
 	}
 
-	if count < maxCount {
-		s.cache.SetWithTTL(key, count+1, 1, s.config.TTL)
+	return s.config.Mode
+}
+
+func (s *Service) newCounter(now time.Time, maxCount int) counter {
+	switch s.config.Mode {
+	case modeSlidingWindow:
+		return &slidingWindow{window: s.config.TTL}
 
-		return false
+	case modeTokenBucket:
+		return &tokenBucket{rate: s.rate(float64(maxCount)), tokens: float64(maxCount), capacity: float64(maxCount), updated: now}
+
+	default:
+		return &fixedWindow{window: s.config.TTL, start: now}
+	}
+}
+
+func (s *Service) rate(capacity float64) float64 {
+	if s.config.Rate == 0 {
+		return capacity / s.config.TTL.Seconds()
 	}
 
-	return true
+	return s.config.Rate
+}
+
+func (s *Service) shard(key string) *shard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+
+	return &s.shards[hash.Sum32()%shardCount]
+}
+
+func (s *Service) cleanup(now time.Time) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+
+		shard.mu.Lock()
+
+		for key, c := range shard.counters {
+			if c.expired(now) {
+				delete(shard.counters, key)
+			}
+		}
+
+		shard.mu.Unlock()
+	}
 }
 
-func makeKey(addr net.IP, source string) string {
-	return fmt.Sprintf("%s:%s", addr.String(), source)
+func makeKey(prefix netip.Prefix, source string) string {
+	return fmt.Sprintf("%s:%s", prefix.String(), source)
 }
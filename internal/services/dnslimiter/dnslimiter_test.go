@@ -0,0 +1,80 @@
+package dnslimiter
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"masquerade-dns/internal/pkg/subnet"
+)
+
+func TestLimitResetsAfterWindow(t *testing.T) {
+	for _, mode := range []string{modeFixedWindow, modeSlidingWindow, modeTokenBucket} {
+		t.Run(mode, func(t *testing.T) {
+			s, err := NewService(&Config{TTL: time.Minute, Mode: mode}, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("NewService() error = %v", err)
+			}
+
+			addr := net.IPv4(192, 0, 2, 1)
+
+			for i := range 3 {
+				if s.limit(epoch, addr, "source", 3, subnet.Config{}) {
+					t.Fatalf("request %d is limited", i)
+				}
+			}
+
+			if !s.limit(epoch, addr, "source", 3, subnet.Config{}) {
+				t.Fatal("request over max count is not limited")
+			}
+
+			if s.limit(epoch, net.IPv4(192, 0, 2, 2), "source", 3, subnet.Config{}) {
+				t.Fatal("other client is limited")
+			}
+
+			if s.limit(epoch, addr, "other", 3, subnet.Config{}) {
+				t.Fatal("other source is limited")
+			}
+
+			if s.limit(epoch.Add(time.Minute), addr, "source", 3, subnet.Config{}) {
+				t.Fatal("request after window is limited")
+			}
+		})
+	}
+}
+
+func TestLimitByPrefix(t *testing.T) {
+	s, err := NewService(&Config{TTL: time.Minute}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	prefix := subnet.Config{IPv4: 24, IPv6: 56}
+
+	if s.limit(epoch, net.IPv4(192, 0, 2, 1), "source", 1, prefix) {
+		t.Fatal("first request is limited")
+	}
+
+	if !s.limit(epoch, net.IPv4(192, 0, 2, 200), "source", 1, prefix) {
+		t.Fatal("request from same prefix is not limited")
+	}
+
+	if s.limit(epoch, net.IPv4(192, 0, 3, 1), "source", 1, prefix) {
+		t.Fatal("request from other prefix is limited")
+	}
+}
+
+func TestLimitUnlimited(t *testing.T) {
+	s, err := NewService(&Config{TTL: time.Minute}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	for range 10 {
+		if s.limit(epoch, net.IPv4(192, 0, 2, 1), "source", 0, subnet.Config{}) {
+			t.Fatal("request without max count is limited")
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package dnslimiter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultSnapshotInterval = time.Minute
+
+type snapshotConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+type snapshot struct {
+	SavedAt  time.Time `json:"savedAt"`
+	Counters []entry   `json:"counters"`
+}
+
+type entry struct {
+	Key      string      `json:"key"`
+	Mode     string      `json:"mode"`
+	Start    time.Time   `json:"start"`
+	Count    int         `json:"count,omitempty"`
+	Hits     []time.Time `json:"hits,omitempty"`
+	Tokens   float64     `json:"tokens,omitempty"`
+	Capacity float64     `json:"capacity,omitempty"`
+}
+
+func (s *Service) save(now time.Time) error {
+	data := snapshot{SavedAt: now}
+
+	for i := range s.shards {
+		shard := &s.shards[i]
+
+		shard.mu.Lock()
+
+		for key, c := range shard.counters {
+			if c.expired(now) {
+				continue
+			}
+
+			e := c.dump()
+			e.Key = key
+
+			data.Counters = append(data.Counters, e)
+		}
+
+		shard.mu.Unlock()
+	}
+
+	path := s.config.Snapshot.Path
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "can't create snapshot file")
+	}
+
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if err := json.NewEncoder(file).Encode(&data); err != nil {
+		_ = file.Close()
+
+		return errors.Wrap(err, "can't write snapshot")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "can't write snapshot")
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return errors.Wrap(err, "can't replace snapshot")
+	}
+
+	return nil
+}
+
+func (s *Service) restore(now time.Time) (int, error) {
+	file, err := os.Open(s.config.Snapshot.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, errors.Wrap(err, "can't open snapshot")
+	}
+
+	defer file.Close()
+
+	var data snapshot
+
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return 0, errors.Wrap(err, "can't read snapshot")
+	}
+
+	restored := 0
+
+	for _, e := range data.Counters {
+		if e.Mode != s.mode() {
+			continue
+		}
+
+		c := s.load(e)
+		if c.expired(now) {
+			continue
+		}
+
+		shard := s.shard(e.Key)
+
+		shard.mu.Lock()
+		shard.counters[e.Key] = c
+		shard.mu.Unlock()
+
+		restored++
+	}
+
+	return restored, nil
+}
+
+func (s *Service) snapshotInterval() time.Duration {
+	if s.config.Snapshot.Interval == 0 {
+		return defaultSnapshotInterval
+	}
+
+	return s.config.Snapshot.Interval
+}
+
+func (s *Service) load(e entry) counter {
+	switch e.Mode {
+	case modeSlidingWindow:
+		return &slidingWindow{window: s.config.TTL, hits: e.Hits}
+
+	case modeTokenBucket:
+		return &tokenBucket{rate: s.rate(e.Capacity), tokens: min(e.Tokens, e.Capacity), capacity: e.Capacity, updated: e.Start}
+
+	default:
+		return &fixedWindow{window: s.config.TTL, start: e.Start, count: e.Count}
+	}
+}
@@ -0,0 +1,100 @@
+package dnslimiter
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"masquerade-dns/internal/pkg/subnet"
+)
+
+func newSnapshotService(t *testing.T, config *Config) *Service {
+	t.Helper()
+
+	s, err := NewService(config, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	return s
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	tests := []struct {
+		mode    string
+		restore Config
+		offset  time.Duration
+		limited bool
+	}{
+		{mode: modeFixedWindow, restore: Config{TTL: 2 * time.Minute}, offset: 90 * time.Second, limited: true},
+		{mode: modeSlidingWindow, restore: Config{TTL: 2 * time.Minute}, offset: 90 * time.Second, limited: true},
+		{mode: modeTokenBucket, restore: Config{TTL: time.Minute, Rate: 0.1}, offset: 10 * time.Second, limited: false},
+	}
+
+	addr := net.IPv4(192, 0, 2, 1)
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "limiter.json")
+
+			saved := newSnapshotService(t, &Config{TTL: time.Minute, Mode: tt.mode, Snapshot: snapshotConfig{Path: path}})
+
+			for i := range 2 {
+				if saved.limit(epoch, addr, "source", 2, subnet.Config{}) {
+					t.Fatalf("request %d is limited", i)
+				}
+			}
+
+			if err := saved.save(epoch); err != nil {
+				t.Fatalf("save() error = %v", err)
+			}
+
+			tt.restore.Mode = tt.mode
+			tt.restore.Snapshot.Path = path
+
+			restored := newSnapshotService(t, &tt.restore)
+
+			n, err := restored.restore(epoch)
+			if err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+
+			if n != 1 {
+				t.Fatalf("restore() = %d counters, want 1", n)
+			}
+
+			if !restored.limit(epoch, addr, "source", 2, subnet.Config{}) {
+				t.Fatal("restored counter does not limit")
+			}
+
+			if got := restored.limit(epoch.Add(tt.offset), addr, "source", 2, subnet.Config{}); got != tt.limited {
+				t.Fatalf("limit() after %s = %t, want %t with the current config", tt.offset, got, tt.limited)
+			}
+		})
+	}
+}
+
+func TestSnapshotSkipsOtherMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limiter.json")
+
+	saved := newSnapshotService(t, &Config{TTL: time.Minute, Mode: modeFixedWindow, Snapshot: snapshotConfig{Path: path}})
+	saved.limit(epoch, net.IPv4(192, 0, 2, 1), "source", 2, subnet.Config{})
+
+	if err := saved.save(epoch); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	restored := newSnapshotService(t, &Config{TTL: time.Minute, Mode: modeSlidingWindow, Snapshot: snapshotConfig{Path: path}})
+
+	n, err := restored.restore(epoch)
+	if err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+
+	if n != 0 {
+		t.Fatalf("restore() = %d counters, want 0 for another mode", n)
+	}
+}
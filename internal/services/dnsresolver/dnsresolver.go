@@ -2,15 +2,17 @@ package dnsresolver
 
 import (
 	"context"
-	"math/rand/v2"
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
 	"masquerade-dns/internal/metrics"
 	"masquerade-dns/internal/pkg/logger"
+	"masquerade-dns/internal/pkg/pattern"
 	"masquerade-dns/internal/pkg/trace"
+	"masquerade-dns/internal/pkg/ttlpolicy"
 )
 
 const (
@@ -23,10 +25,25 @@ type nameserver struct {
 	Network string `yaml:"network"`
 }
 
+type groupConfig struct {
+	Name        string            `env-required:"true" yaml:"name"`
+	Mode        string            `env-required:"true" yaml:"mode"`
+	Nameservers []nameserver      `env-required:"true" yaml:"nameservers"`
+	TTLPolicy   *ttlpolicy.Config `yaml:"ttlPolicy"`
+}
+
+type routeConfig struct {
+	Domain string `env-required:"true" yaml:"domain"`
+	Group  string `env-required:"true" yaml:"group"`
+}
+
 type Config struct {
-	Timeout     time.Duration `env-required:"true" yaml:"timeout"`
-	Mode        string        `env-required:"true" yaml:"mode"`
-	Nameservers []nameserver  `env-required:"true" yaml:"nameservers"`
+	Timeout     time.Duration     `env-required:"true" yaml:"timeout"`
+	Mode        string            `env-required:"true" yaml:"mode"`
+	Nameservers []nameserver      `env-required:"true" yaml:"nameservers"`
+	Groups      []groupConfig     `yaml:"groups"`
+	Routes      []routeConfig     `yaml:"routes"`
+	TTLPolicy   *ttlpolicy.Config `yaml:"ttlPolicy"`
 }
 
 type Service struct {
@@ -34,25 +51,67 @@ type Service struct {
 	metrics *metrics.Metrics
 	logger  *zap.SugaredLogger
 
-	index int
+	defaultGroup *group
+	routes       map[string]*group
 }
 
 func NewService(
 	config *Config,
 	metrics *metrics.Metrics,
 	logger *zap.SugaredLogger,
-) *Service {
-	return &Service{
-		config:  config,
-		metrics: metrics,
-		logger:  logger,
+) (*Service, error) {
+	defaultGroup, err := newGroup(defaultGroup, config.Mode, config.Nameservers, config.TTLPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*group{
+		defaultGroup.name: defaultGroup,
+	}
+
+	for _, groupConfig := range config.Groups {
+		if _, ok := groups[groupConfig.Name]; ok {
+			return nil, errors.Errorf("resolver group %q is duplicated", groupConfig.Name)
+		}
+
+		ttlPolicy := groupConfig.TTLPolicy
+		if ttlPolicy == nil {
+			ttlPolicy = config.TTLPolicy
+		}
+
+		group, err := newGroup(groupConfig.Name, groupConfig.Mode, groupConfig.Nameservers, ttlPolicy)
+		if err != nil {
+			return nil, err
+		}
+
+		groups[group.name] = group
 	}
+
+	routes := make(map[string]*group, len(config.Routes))
+
+	for _, route := range config.Routes {
+		group, ok := groups[route.Group]
+		if !ok {
+			return nil, errors.Errorf("resolver group %q is not found for domain %q", route.Group, route.Domain)
+		}
+
+		routes[pattern.CanonicalName(route.Domain)] = group
+	}
+
+	return &Service{
+		config:       config,
+		metrics:      metrics,
+		logger:       logger,
+		defaultGroup: defaultGroup,
+		routes:       routes,
+	}, nil
 }
 
 func (s *Service) Lookup(ctx context.Context, req *dns.Msg) *dns.Msg {
 	traceID := trace.UnpackTraceID(ctx)
 
-	nameserver := s.nameserver()
+	group := s.group(req)
+	nameserver := group.nameserver()
 
 	client := &dns.Client{
 		Net:     nameserver.Network,
@@ -64,6 +123,8 @@ func (s *Service) Lookup(ctx context.Context, req *dns.Msg) *dns.Msg {
 		s.logger.Errorw(
 			"Can't lookup DNS request",
 			logger.TraceID(traceID),
+			"group", group.name,
+			"nameserver", nameserver.Address,
 			logger.Error(err),
 		)
 
@@ -75,6 +136,8 @@ func (s *Service) Lookup(ctx context.Context, req *dns.Msg) *dns.Msg {
 		return resp
 	}
 
+	group.ttlPolicy.Apply(resp.Answer, resp.Ns, resp.Extra)
+
 	if resp.Rcode != dns.RcodeSuccess {
 		s.logger.Warnw("Invalid DNS response", logger.TraceID(traceID))
 
@@ -88,23 +151,18 @@ func (s *Service) Lookup(ctx context.Context, req *dns.Msg) *dns.Msg {
 	return resp
 }
 
-func (s *Service) nameserver() nameserver {
-	switch s.config.Mode {
-	case modeRandom:
-		return s.config.Nameservers[rand.IntN(len(s.config.Nameservers))]
-
-	case modeRoundRobin:
-		if s.index >= len(s.config.Nameservers) {
-			s.index = 0
-		}
-
-		nameserver := s.config.Nameservers[s.index]
-
-		s.index++
+func (s *Service) group(req *dns.Msg) *group {
+	if len(s.routes) == 0 || len(req.Question) == 0 {
+		return s.defaultGroup
+	}
 
-		return nameserver
+	name := pattern.CanonicalName(req.Question[0].Name)
 
-	default:
-		panic("resolver mode is not supported")
+	for offset, end := 0, false; !end; offset, end = dns.NextLabel(name, offset) {
+		if group, ok := s.routes[name[offset:]]; ok {
+			return group
+		}
 	}
+
+	return s.defaultGroup
 }
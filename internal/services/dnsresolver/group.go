@@ -0,0 +1,61 @@
+package dnsresolver
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"masquerade-dns/internal/pkg/ttlpolicy"
+)
+
+const defaultGroup = "default"
+
+type group struct {
+	name        string
+	mode        string
+	nameservers []nameserver
+	ttlPolicy   *ttlpolicy.Config
+
+	index atomic.Uint64
+}
+
+func newGroup(name, mode string, nameservers []nameserver, ttlPolicy *ttlpolicy.Config) (*group, error) {
+	switch mode {
+	case modeRandom, modeRoundRobin:
+	default:
+		return nil, errors.Errorf("resolver mode %q is not supported for group %q", mode, name)
+	}
+
+	if len(nameservers) == 0 {
+		return nil, errors.Errorf("nameservers are not set for group %q", name)
+	}
+
+	if ttlPolicy != nil {
+		if err := ttlPolicy.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "can't parse TTL policy for group %q", name)
+		}
+	}
+
+	return &group{
+		name:        name,
+		mode:        mode,
+		nameservers: nameservers,
+		ttlPolicy:   ttlPolicy,
+	}, nil
+}
+
+func (g *group) nameserver() nameserver {
+	switch g.mode {
+	case modeRandom:
+		return g.nameservers[rand.IntN(len(g.nameservers))]
+
+	case modeRoundRobin:
+		index := (g.index.Add(1) - 1) % uint64(len(g.nameservers))
+
+		return g.nameservers[index]
+
+	default:
+		panic("resolver mode is not supported")
+	}
+}
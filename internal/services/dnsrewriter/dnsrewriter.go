@@ -0,0 +1,233 @@
+package dnsrewriter
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"masquerade-dns/internal/pkg/logger"
+	"masquerade-dns/internal/pkg/pattern"
+	"masquerade-dns/internal/pkg/trace"
+)
+
+const maxSVCBKey = 16
+
+type mappingConfig struct {
+	From netip.Prefix `env-required:"true" yaml:"from"`
+	To   netip.Prefix `env-required:"true" yaml:"to"`
+}
+
+type rewriteConfig struct {
+	Source      string          `env-required:"true" yaml:"source"`
+	Mapping     []mappingConfig `yaml:"mapping"`
+	Strip       []string        `yaml:"strip"`
+	StripParams []string        `yaml:"stripParams"`
+}
+
+type Config struct {
+	Rules []rewriteConfig `yaml:"rules"`
+}
+
+type rule struct {
+	pattern     *pattern.Pattern
+	mapping     []mappingConfig
+	strip       map[uint16]bool
+	stripParams map[dns.SVCBKey]bool
+}
+
+type Service struct {
+	config *Config
+	logger *zap.SugaredLogger
+
+	rules []*rule
+}
+
+func NewService(
+	config *Config,
+	logger *zap.SugaredLogger,
+) (*Service, error) {
+	rules := make([]*rule, 0, len(config.Rules))
+
+	for i := range config.Rules {
+		rule, err := newRule(&config.Rules[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't parse rewrite rule %q", config.Rules[i].Source)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return &Service{
+		config: config,
+		logger: logger,
+		rules:  rules,
+	}, nil
+}
+
+func (s *Service) Rewrite(ctx context.Context, resp *dns.Msg) *dns.Msg {
+	traceID := trace.UnpackTraceID(ctx)
+
+	if len(resp.Question) == 0 || resp.Rcode != dns.RcodeSuccess {
+		return resp
+	}
+
+	name := resp.Question[0].Name
+
+	for _, rule := range s.rules {
+		if !rule.pattern.Match(name) {
+			continue
+		}
+
+		s.logger.Infow("Rewrite DNS response", logger.TraceID(traceID))
+
+		resp = resp.Copy()
+		resp.Answer = rule.rewrite(resp.Answer)
+		resp.Extra = rule.rewrite(resp.Extra)
+
+		return resp
+	}
+
+	return resp
+}
+
+func newRule(config *rewriteConfig) (*rule, error) {
+	p, err := pattern.Parse(config.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mapping := range config.Mapping {
+		if !mapping.From.IsValid() || !mapping.To.IsValid() {
+			return nil, errors.New("mapping prefix is not valid")
+		}
+
+		if mapping.From.Bits() != mapping.To.Bits() || mapping.From.Addr().Is4() != mapping.To.Addr().Is4() {
+			return nil, errors.Errorf("mapping prefixes %s and %s don't match", mapping.From, mapping.To)
+		}
+	}
+
+	strip := make(map[uint16]bool, len(config.Strip))
+
+	for _, name := range config.Strip {
+		rrtype, ok := dns.StringToType[strings.ToUpper(name)]
+		if !ok {
+			return nil, errors.Errorf("record type %q is not supported", name)
+		}
+
+		strip[rrtype] = true
+	}
+
+	stripParams := make(map[dns.SVCBKey]bool, len(config.StripParams))
+
+	for _, name := range config.StripParams {
+		key, ok := parseSVCBKey(name)
+		if !ok {
+			return nil, errors.Errorf("SVCB parameter %q is not supported", name)
+		}
+
+		stripParams[key] = true
+	}
+
+	return &rule{
+		pattern:     p,
+		mapping:     config.Mapping,
+		strip:       strip,
+		stripParams: stripParams,
+	}, nil
+}
+
+func (r *rule) rewrite(records []dns.RR) []dns.RR {
+	rewritten := records[:0]
+
+	for _, record := range records {
+		if r.strip[record.Header().Rrtype] {
+			continue
+		}
+
+		switch t := record.(type) {
+		case *dns.A:
+			t.A = r.mapAddr(t.A)
+
+		case *dns.AAAA:
+			t.AAAA = r.mapAddr(t.AAAA)
+
+		case *dns.HTTPS:
+			t.Value = r.rewriteSVCB(t.Value)
+
+		case *dns.SVCB:
+			t.Value = r.rewriteSVCB(t.Value)
+		}
+
+		rewritten = append(rewritten, record)
+	}
+
+	return rewritten
+}
+
+func (r *rule) mapAddr(ip net.IP) net.IP {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return ip
+	}
+
+	addr = addr.Unmap()
+
+	for _, mapping := range r.mapping {
+		if !mapping.From.Contains(addr) {
+			continue
+		}
+
+		mapped := addr.AsSlice()
+		to := mapping.To.Addr().AsSlice()
+		mask := net.CIDRMask(mapping.To.Bits(), len(to)*8)
+
+		for i := range mapped {
+			mapped[i] = to[i]&mask[i] | mapped[i]&^mask[i]
+		}
+
+		return mapped
+	}
+
+	return ip
+}
+
+func (r *rule) rewriteSVCB(values []dns.SVCBKeyValue) []dns.SVCBKeyValue {
+	rewritten := values[:0]
+
+	for _, value := range values {
+		if r.stripParams[value.Key()] {
+			continue
+		}
+
+		switch t := value.(type) {
+		case *dns.SVCBIPv4Hint:
+			for i := range t.Hint {
+				t.Hint[i] = r.mapAddr(t.Hint[i])
+			}
+
+		case *dns.SVCBIPv6Hint:
+			for i := range t.Hint {
+				t.Hint[i] = r.mapAddr(t.Hint[i])
+			}
+		}
+
+		rewritten = append(rewritten, value)
+	}
+
+	return rewritten
+}
+
+func parseSVCBKey(name string) (dns.SVCBKey, bool) {
+	for key := dns.SVCBKey(0); key < maxSVCBKey; key++ {
+		if strings.EqualFold(key.String(), name) {
+			return key, true
+		}
+	}
+
+	return 0, false
+}
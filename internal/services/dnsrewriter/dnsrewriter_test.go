@@ -0,0 +1,54 @@
+package dnsrewriter
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRuleRewriteMapsAddresses(t *testing.T) {
+	r, err := newRule(&rewriteConfig{
+		Source: ".test",
+		Mapping: []mappingConfig{
+			{From: netip.MustParsePrefix("203.0.113.0/24"), To: netip.MustParsePrefix("10.0.113.0/24")},
+			{From: netip.MustParsePrefix("2001:db8::/64"), To: netip.MustParsePrefix("fd00::/64")},
+		},
+		StripParams: []string{"ech"},
+	})
+	if err != nil {
+		t.Fatalf("newRule() error = %v", err)
+	}
+
+	a := &dns.A{Hdr: dns.RR_Header{Rrtype: dns.TypeA}, A: net.ParseIP("203.0.113.7")}
+	https := &dns.HTTPS{SVCB: dns.SVCB{
+		Hdr: dns.RR_Header{Rrtype: dns.TypeHTTPS},
+		Value: []dns.SVCBKeyValue{
+			&dns.SVCBIPv4Hint{Hint: []net.IP{net.ParseIP("203.0.113.8"), net.ParseIP("198.51.100.1")}},
+			&dns.SVCBIPv6Hint{Hint: []net.IP{net.ParseIP("2001:db8::9")}},
+			&dns.SVCBECHConfig{ECH: []byte{1}},
+		},
+	}}
+
+	records := r.rewrite([]dns.RR{a, https})
+
+	if got := records[0].(*dns.A).A; !got.Equal(net.ParseIP("10.0.113.7")) {
+		t.Errorf("A = %s, want 10.0.113.7", got)
+	}
+
+	values := records[1].(*dns.HTTPS).Value
+	if len(values) != 2 {
+		t.Fatalf("SVCB values = %v, want ech stripped", values)
+	}
+
+	ipv4 := values[0].(*dns.SVCBIPv4Hint).Hint
+	if !ipv4[0].Equal(net.ParseIP("10.0.113.8")) || !ipv4[1].Equal(net.ParseIP("198.51.100.1")) {
+		t.Errorf("ipv4hint = %v, want [10.0.113.8 198.51.100.1]", ipv4)
+	}
+
+	ipv6 := values[1].(*dns.SVCBIPv6Hint).Hint
+	if !ipv6[0].Equal(net.ParseIP("fd00::9")) {
+		t.Errorf("ipv6hint = %v, want [fd00::9]", ipv6)
+	}
+}
@@ -0,0 +1,41 @@
+package dnsrrl
+
+import (
+	"time"
+)
+
+type bucket struct {
+	rate    float64
+	balance float64
+	floor   float64
+	updated time.Time
+	limited int
+}
+
+func newBucket(now time.Time, rate float64, window time.Duration) *bucket {
+	return &bucket{
+		rate:    rate,
+		balance: rate,
+		floor:   -rate * window.Seconds(),
+		updated: now,
+	}
+}
+
+func (b *bucket) allow(now time.Time) bool {
+	b.balance = min(b.rate, b.balance+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+
+	b.balance = max(b.floor, b.balance-1)
+
+	return b.balance >= 0
+}
+
+func (b *bucket) slip(slip int) bool {
+	b.limited++
+
+	return b.limited%slip == 0
+}
+
+func (b *bucket) expired(now time.Time) bool {
+	return b.balance+now.Sub(b.updated).Seconds()*b.rate >= b.rate
+}
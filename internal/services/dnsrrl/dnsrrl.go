@@ -0,0 +1,278 @@
+package dnsrrl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	"masquerade-dns/internal/pkg/subnet"
+)
+
+type Action int
+
+const (
+	ActionSend Action = iota
+	ActionDrop
+	ActionSlip
+)
+
+const (
+	categoryResponse = "response"
+	categoryNXDomain = "nxdomain"
+	categoryNoData   = "nodata"
+	categoryError    = "error"
+)
+
+const defaultWindow = 15 * time.Second
+
+var defaultPrefix = subnet.Config{IPv4: 24, IPv6: 56}
+
+const (
+	shardCount      = 64
+	cleanupInterval = time.Minute
+)
+
+type Config struct {
+	Enabled            bool          `yaml:"enabled"`
+	ResponsesPerSecond int           `yaml:"responsesPerSecond"`
+	NXDomainsPerSecond int           `yaml:"nxdomainsPerSecond"`
+	NoDataPerSecond    int           `yaml:"nodataPerSecond"`
+	ErrorsPerSecond    int           `yaml:"errorsPerSecond"`
+	Window             time.Duration `yaml:"window"`
+	Slip               int           `yaml:"slip"`
+	Prefix             subnet.Config `yaml:"prefix"`
+}
+
+type shard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type Service struct {
+	config *Config
+
+	window time.Duration
+	prefix subnet.Config
+
+	shards [shardCount]shard
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewService(config *Config) (*Service, error) {
+	if config.ResponsesPerSecond < 0 ||
+		config.NXDomainsPerSecond < 0 ||
+		config.NoDataPerSecond < 0 ||
+		config.ErrorsPerSecond < 0 {
+		return nil, errors.New("RRL budgets must not be negative")
+	}
+
+	if config.Window < 0 {
+		return nil, errors.New("RRL window must not be negative")
+	}
+
+	if config.Slip < 0 {
+		return nil, errors.New("RRL slip must not be negative")
+	}
+
+	s := &Service{
+		config: config,
+		window: config.Window,
+		prefix: config.Prefix.Or(defaultPrefix),
+		done:   make(chan struct{}),
+	}
+
+	if s.window == 0 {
+		s.window = defaultWindow
+	}
+
+	if err := s.prefix.Validate(); err != nil {
+		return nil, errors.Wrap(err, "can't use RRL prefix length")
+	}
+
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*bucket)
+	}
+
+	return s, nil
+}
+
+func (s *Service) Start() {
+	if !s.config.Enabled {
+		return
+	}
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+
+			case now := <-ticker.C:
+				s.cleanup(now)
+			}
+		}
+	}()
+}
+
+func (s *Service) Shutdown() {
+	close(s.done)
+
+	s.wg.Wait()
+}
+
+func (s *Service) Limit(addr net.IP, resp *dns.Msg) Action {
+	return s.limit(time.Now(), addr, resp)
+}
+
+func (s *Service) limit(now time.Time, addr net.IP, resp *dns.Msg) Action {
+	if !s.config.Enabled {
+		return ActionSend
+	}
+
+	category := classify(resp)
+
+	rate := s.rate(category)
+	if rate == 0 {
+		return ActionSend
+	}
+
+	key := s.makeKey(addr, category, resp)
+
+	shard := s.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, ok := shard.buckets[key]
+	if !ok || b.expired(now) {
+		b = newBucket(now, float64(rate), s.window)
+		shard.buckets[key] = b
+	}
+
+	if b.allow(now) {
+		return ActionSend
+	}
+
+	if s.config.Slip > 0 && b.slip(s.config.Slip) {
+		return ActionSlip
+	}
+
+	return ActionDrop
+}
+
+func (s *Service) rate(category string) int {
+	switch category {
+	case categoryNXDomain:
+		return orDefault(s.config.NXDomainsPerSecond, s.config.ResponsesPerSecond)
+
+	case categoryNoData:
+		return orDefault(s.config.NoDataPerSecond, s.config.ResponsesPerSecond)
+
+	case categoryError:
+		return orDefault(s.config.ErrorsPerSecond, s.config.ResponsesPerSecond)
+
+	default:
+		return s.config.ResponsesPerSecond
+	}
+}
+
+func (s *Service) makeKey(addr net.IP, category string, resp *dns.Msg) string {
+	prefix := s.prefix.Prefix(addr)
+
+	switch category {
+	case categoryError:
+		return fmt.Sprintf("%s:%s", prefix, category)
+
+	case categoryNXDomain:
+		return fmt.Sprintf("%s:%s:%s", prefix, category, domain(resp))
+
+	default:
+		var (
+			name  string
+			qtype uint16
+		)
+
+		if len(resp.Question) > 0 {
+			name = strings.ToLower(resp.Question[0].Name)
+			qtype = resp.Question[0].Qtype
+		}
+
+		return fmt.Sprintf("%s:%s:%s:%d", prefix, category, name, qtype)
+	}
+}
+
+func (s *Service) shard(key string) *shard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+
+	return &s.shards[hash.Sum32()%shardCount]
+}
+
+func (s *Service) cleanup(now time.Time) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+
+		shard.mu.Lock()
+
+		for key, b := range shard.buckets {
+			if b.expired(now) {
+				delete(shard.buckets, key)
+			}
+		}
+
+		shard.mu.Unlock()
+	}
+}
+
+func classify(resp *dns.Msg) string {
+	switch {
+	case resp.Rcode == dns.RcodeNameError:
+		return categoryNXDomain
+
+	case resp.Rcode != dns.RcodeSuccess:
+		return categoryError
+
+	case len(resp.Answer) == 0:
+		return categoryNoData
+
+	default:
+		return categoryResponse
+	}
+}
+
+func domain(resp *dns.Msg) string {
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return strings.ToLower(soa.Hdr.Name)
+		}
+	}
+
+	if len(resp.Question) > 0 {
+		return strings.ToLower(resp.Question[0].Name)
+	}
+
+	return ""
+}
+
+func orDefault(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,137 @@
+package dnsrrl
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var epoch = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func newResponse(name string, rcode int, answers int) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetQuestion(name, dns.TypeA)
+	resp.Rcode = rcode
+
+	for range answers {
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+			A:   net.IPv4(192, 0, 2, 1),
+		})
+	}
+
+	return resp
+}
+
+func newTestService(t *testing.T, config *Config) *Service {
+	t.Helper()
+
+	config.Enabled = true
+
+	s, err := NewService(config)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	return s
+}
+
+func TestLimitBudget(t *testing.T) {
+	s := newTestService(t, &Config{ResponsesPerSecond: 2})
+
+	addr := net.IPv4(192, 0, 2, 1)
+	resp := newResponse("example.test.", dns.RcodeSuccess, 1)
+
+	want := []Action{ActionSend, ActionSend, ActionDrop, ActionDrop}
+
+	for i, action := range want {
+		if got := s.limit(epoch, addr, resp); got != action {
+			t.Errorf("response %d: limit() = %v, want %v", i, got, action)
+		}
+	}
+
+	if got := s.limit(epoch, net.IPv4(192, 0, 2, 200), resp); got != ActionDrop {
+		t.Errorf("same /24 prefix: limit() = %v, want %v", got, ActionDrop)
+	}
+
+	if got := s.limit(epoch, net.IPv4(192, 0, 3, 1), resp); got != ActionSend {
+		t.Errorf("other prefix: limit() = %v, want %v", got, ActionSend)
+	}
+
+	if got := s.limit(epoch, addr, newResponse("other.test.", dns.RcodeSuccess, 1)); got != ActionSend {
+		t.Errorf("other name: limit() = %v, want %v", got, ActionSend)
+	}
+}
+
+func TestLimitCategories(t *testing.T) {
+	s := newTestService(t, &Config{ResponsesPerSecond: 1, NXDomainsPerSecond: 2})
+
+	addr := net.IPv4(192, 0, 2, 1)
+
+	if got := s.limit(epoch, addr, newResponse("example.test.", dns.RcodeSuccess, 1)); got != ActionSend {
+		t.Errorf("response: limit() = %v, want %v", got, ActionSend)
+	}
+
+	if got := s.limit(epoch, addr, newResponse("example.test.", dns.RcodeSuccess, 0)); got != ActionSend {
+		t.Errorf("nodata: limit() = %v, want %v", got, ActionSend)
+	}
+
+	for i, action := range []Action{ActionSend, ActionSend, ActionDrop} {
+		resp := newResponse("missing.example.test.", dns.RcodeNameError, 0)
+
+		if got := s.limit(epoch, addr, resp); got != action {
+			t.Errorf("nxdomain %d: limit() = %v, want %v", i, got, action)
+		}
+	}
+}
+
+func TestLimitSlip(t *testing.T) {
+	s := newTestService(t, &Config{ResponsesPerSecond: 1, Slip: 2})
+
+	addr := net.IPv4(192, 0, 2, 1)
+	resp := newResponse("example.test.", dns.RcodeSuccess, 1)
+
+	want := []Action{ActionSend, ActionDrop, ActionSlip, ActionDrop, ActionSlip}
+
+	for i, action := range want {
+		if got := s.limit(epoch, addr, resp); got != action {
+			t.Errorf("response %d: limit() = %v, want %v", i, got, action)
+		}
+	}
+}
+
+func TestLimitWindow(t *testing.T) {
+	s := newTestService(t, &Config{ResponsesPerSecond: 1, Window: 5 * time.Second})
+
+	addr := net.IPv4(192, 0, 2, 1)
+	resp := newResponse("example.test.", dns.RcodeSuccess, 1)
+
+	for range 20 {
+		s.limit(epoch, addr, resp)
+	}
+
+	if got := s.limit(epoch.Add(time.Second), addr, resp); got != ActionDrop {
+		t.Errorf("after flood: limit() = %v, want %v", got, ActionDrop)
+	}
+
+	if got := s.limit(epoch.Add(8*time.Second), addr, resp); got != ActionSend {
+		t.Errorf("after window: limit() = %v, want %v", got, ActionSend)
+	}
+}
+
+func TestLimitDisabled(t *testing.T) {
+	s, err := NewService(&Config{ResponsesPerSecond: 1})
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	resp := newResponse("example.test.", dns.RcodeSuccess, 1)
+
+	for range 5 {
+		if got := s.limit(epoch, net.IPv4(192, 0, 2, 1), resp); got != ActionSend {
+			t.Fatalf("limit() = %v, want %v", got, ActionSend)
+		}
+	}
+}
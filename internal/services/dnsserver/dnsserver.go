@@ -13,116 +13,125 @@ import (
 	"masquerade-dns/internal/metrics"
 	"masquerade-dns/internal/pkg/logger"
 	"masquerade-dns/internal/pkg/trace"
+	"masquerade-dns/internal/services/dnsrrl"
+	"masquerade-dns/internal/services/dnsview"
 )
 
 const handlerPattern = "."
 
-type dnsResolver interface {
-	Lookup(ctx context.Context, req *dns.Msg) *dns.Msg
+type dnsRewriter interface {
+	Rewrite(ctx context.Context, resp *dns.Msg) *dns.Msg
 }
 
-type dnsSwitcher interface {
-	Switch(ctx context.Context, addr net.IP, req *dns.Msg) (*dns.Msg, bool)
+type dnsViews interface {
+	Select(listener string, addr net.IP) *dnsview.View
+}
+
+type dnsRRL interface {
+	Limit(addr net.IP, resp *dns.Msg) dnsrrl.Action
 }
 
 type Config struct {
-	Host    string        `env-required:"true" yaml:"host"`
-	Port    string        `env-required:"true" yaml:"port"`
-	Timeout time.Duration `env-required:"true" yaml:"timeout"`
+	Host      string        `env-required:"true" yaml:"host"`
+	Port      string        `env-required:"true" yaml:"port"`
+	Listeners []string      `yaml:"listeners"`
+	Timeout   time.Duration `env-required:"true" yaml:"timeout"`
+	ECS       ecsConfig     `yaml:"ecs"`
 }
 
 type Service struct {
 	config   *Config
 	metrics  *metrics.Metrics
-	resolver dnsResolver
-	switcher dnsSwitcher
+	views    dnsViews
+	rewriter dnsRewriter
+	rrl      dnsRRL
 	logger   *zap.SugaredLogger
 
-	tcpServer *dns.Server
-	udpServer *dns.Server
+	servers []*dns.Server
 }
 
 func NewService(
 	config *Config,
 	metrics *metrics.Metrics,
-	resolver dnsResolver,
-	switcher dnsSwitcher,
+	views dnsViews,
+	rewriter dnsRewriter,
+	rrl dnsRRL,
 	logger *zap.SugaredLogger,
-) *Service {
+) (*Service, error) {
+	if config.ECS.Enabled && len(config.ECS.Trusted) == 0 {
+		return nil, errors.New("ECS trusted prefixes are not set")
+	}
+
+	for _, prefix := range config.ECS.Trusted {
+		if !prefix.IsValid() {
+			return nil, errors.New("ECS trusted prefix is not valid")
+		}
+	}
+
 	return &Service{
 		config:   config,
 		metrics:  metrics,
-		resolver: resolver,
-		switcher: switcher,
+		views:    views,
+		rewriter: rewriter,
+		rrl:      rrl,
 		logger:   logger,
-	}
+	}, nil
 }
 
 func (s *Service) Start() {
-	go func() {
-		if err := s.startTCPServer(); err != nil {
-			s.logger.Fatalw("Can't start TCP server", logger.Error(err))
-		}
-	}()
-
-	go func() {
-		if err := s.startUDPServer(); err != nil {
-			s.logger.Fatalw("Can't start UDP server", logger.Error(err))
-		}
-	}()
-}
-
-func (s *Service) Shutdown() error {
-	if err := s.tcpServer.Shutdown(); err != nil {
-		return errors.Wrap(err, "can't shutdown TCP server")
-	}
+	listeners := append([]string{net.JoinHostPort(s.config.Host, s.config.Port)}, s.config.Listeners...)
 
-	if err := s.udpServer.Shutdown(); err != nil {
-		return errors.Wrap(err, "can't shutdown UDP server")
-	}
+	for _, listener := range listeners {
+		tcpServer := s.newServer(listener, "tcp")
+		udpServer := s.newServer(listener, "udp")
 
-	return nil
-}
+		s.servers = append(s.servers, tcpServer, udpServer)
 
-func (s *Service) startTCPServer() error {
-	handler := dns.NewServeMux()
-	handler.HandleFunc(handlerPattern, s.handler)
+		go func() {
+			if err := tcpServer.ListenAndServe(); err != nil {
+				s.logger.Fatalw("Can't start TCP server", "listener", listener, logger.Error(err))
+			}
+		}()
 
-	s.tcpServer = &dns.Server{
-		Addr:         net.JoinHostPort(s.config.Host, s.config.Port),
-		Net:          "tcp",
-		Handler:      handler,
-		ReadTimeout:  s.config.Timeout,
-		WriteTimeout: s.config.Timeout,
+		go func() {
+			if err := udpServer.ListenAndServe(); err != nil {
+				s.logger.Fatalw("Can't start UDP server", "listener", listener, logger.Error(err))
+			}
+		}()
 	}
+}
 
-	if err := s.tcpServer.ListenAndServe(); err != nil {
-		return errors.Wrap(err, "can't start TCP server")
+func (s *Service) Shutdown() error {
+	for _, server := range s.servers {
+		if err := server.Shutdown(); err != nil {
+			return errors.Wrapf(err, "can't shutdown %s server %s", server.Net, server.Addr)
+		}
 	}
 
 	return nil
 }
 
-func (s *Service) startUDPServer() error {
+func (s *Service) newServer(listener string, network string) *dns.Server {
+	host, port, err := net.SplitHostPort(listener)
+	if err == nil {
+		listener = net.JoinHostPort(host, port)
+	}
+
 	handler := dns.NewServeMux()
-	handler.HandleFunc(handlerPattern, s.handler)
+	handler.HandleFunc(handlerPattern, func(w dns.ResponseWriter, req *dns.Msg) {
+		s.handler(listener, w, req)
+	})
 
-	s.udpServer = &dns.Server{
-		Addr:         net.JoinHostPort(s.config.Host, s.config.Port),
-		Net:          "udp",
+	return &dns.Server{
+		Addr:         listener,
+		Net:          network,
 		Handler:      handler,
 		ReadTimeout:  s.config.Timeout,
 		WriteTimeout: s.config.Timeout,
 	}
-
-	if err := s.udpServer.ListenAndServe(); err != nil {
-		return errors.Wrap(err, "can't start UDP server")
-	}
-
-	return nil
 }
 
-func (s *Service) handler(w dns.ResponseWriter, req *dns.Msg) {
+func (s *Service) handler(listener string, w dns.ResponseWriter, req *dns.Msg) {
 	timer := s.metrics.NewDNSRequestsTimer()
 	defer timer.ObserveDuration()
 
@@ -142,26 +151,75 @@ func (s *Service) handler(w dns.ResponseWriter, req *dns.Msg) {
 
 	addr := parseIPAddr(w.RemoteAddr())
 
+	clientAddr := addr
+
+	subnet := s.clientSubnet(addr, req)
+	if subnet != nil {
+		clientAddr = subnet.Address
+	}
+
+	view := s.views.Select(listener, clientAddr)
+
 	s.logger.Infow(
 		"Handle DNS request",
 		logger.TraceID(traceID),
 		"from", addr,
+		"client", clientAddr,
+		"view", view.Name,
 		"question", formatDNSQuestion(req.Question),
 	)
 
 	s.metrics.IncTotalDNSRequests(addr)
 
-	if resp, ok := s.switcher.Switch(ctx, addr, req); ok {
-		s.sendResponse(ctx, w, resp)
+	resp, ok := view.Switcher.Switch(ctx, clientAddr, req)
+
+	switch {
+	case ok && resp == nil:
+		s.logger.Infow("Drop DNS request", logger.TraceID(traceID))
 
 		return
+
+	case ok:
+		if subnet != nil {
+			setClientSubnet(resp, subnet)
+		}
+
+	default:
+		resp = view.Resolver.Lookup(ctx, req)
+		resp = s.rewriter.Rewrite(ctx, resp)
 	}
 
-	resp := s.resolver.Lookup(ctx, req)
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		switch s.rrl.Limit(addr, resp) {
+		case dnsrrl.ActionDrop:
+			s.logger.Infow("Drop DNS response by rate limit", logger.TraceID(traceID))
+
+			s.metrics.IncRateLimitedDNSResponses(metrics.RRLDrop)
+
+			return
+
+		case dnsrrl.ActionSlip:
+			s.logger.Infow("Slip DNS response by rate limit", logger.TraceID(traceID))
+
+			s.metrics.IncRateLimitedDNSResponses(metrics.RRLSlip)
+
+			resp = truncate(req)
+
+		case dnsrrl.ActionSend:
+		}
+	}
 
 	s.sendResponse(ctx, w, resp)
 }
 
+func truncate(req *dns.Msg) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetReply(req)
+	resp.Truncated = true
+
+	return resp
+}
+
 func (s *Service) sendResponse(ctx context.Context, w dns.ResponseWriter, resp *dns.Msg) {
 	traceID := trace.UnpackTraceID(ctx)
 
@@ -235,6 +293,24 @@ func formatDNSQType(qtype uint16) string {
 
 	case dns.TypeHTTPS:
 		return "HTTPS"
+
+	case dns.TypeMX:
+		return "MX"
+
+	case dns.TypeTXT:
+		return "TXT"
+
+	case dns.TypeSRV:
+		return "SRV"
+
+	case dns.TypeNS:
+		return "NS"
+
+	case dns.TypePTR:
+		return "PTR"
+
+	case dns.TypeCAA:
+		return "CAA"
 	}
 
 	return strconv.Itoa(int(qtype))
@@ -0,0 +1,86 @@
+package dnsserver
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	ecsFamilyIPv4 = 1
+	ecsFamilyIPv6 = 2
+
+	ecsUDPSize = 4096
+)
+
+type ecsConfig struct {
+	Enabled bool           `yaml:"enabled"`
+	Trusted []netip.Prefix `yaml:"trusted"`
+}
+
+func (s *Service) clientSubnet(addr net.IP, req *dns.Msg) *dns.EDNS0_SUBNET {
+	if !s.config.ECS.Enabled || !s.trusted(addr) {
+		return nil
+	}
+
+	opt := req.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+
+	for _, option := range opt.Option {
+		subnet, ok := option.(*dns.EDNS0_SUBNET)
+		if !ok || subnet.SourceNetmask == 0 {
+			continue
+		}
+
+		switch subnet.Family {
+		case ecsFamilyIPv4:
+			if subnet.Address.To4() != nil {
+				return subnet
+			}
+
+		case ecsFamilyIPv6:
+			if subnet.Address.To16() != nil {
+				return subnet
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) trusted(addr net.IP) bool {
+	remoteAddr, ok := netip.AddrFromSlice(addr)
+	if !ok {
+		return false
+	}
+
+	remoteAddr = remoteAddr.Unmap()
+
+	for _, prefix := range s.config.ECS.Trusted {
+		if prefix.Contains(remoteAddr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setClientSubnet(resp *dns.Msg, subnet *dns.EDNS0_SUBNET) {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		resp.SetEdns0(ecsUDPSize, false)
+
+		opt = resp.IsEdns0()
+	}
+
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        subnet.Family,
+		SourceNetmask: subnet.SourceNetmask,
+		SourceScope:   subnet.SourceNetmask,
+		Address:       subnet.Address,
+	})
+}
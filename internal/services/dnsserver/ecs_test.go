@@ -0,0 +1,51 @@
+package dnsserver
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewServiceRequiresTrustedECSPrefixes(t *testing.T) {
+	config := &Config{ECS: ecsConfig{Enabled: true}}
+
+	if _, err := NewService(config, nil, nil, nil, nil, nil); err == nil {
+		t.Fatal("expected error for ECS without trusted prefixes")
+	}
+}
+
+func TestClientSubnet(t *testing.T) {
+	req := &dns.Msg{}
+	req.SetQuestion("example.test.", dns.TypeA)
+	req.SetEdns0(ecsUDPSize, false)
+	req.IsEdns0().Option = append(req.IsEdns0().Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        ecsFamilyIPv4,
+		SourceNetmask: 24,
+		Address:       net.ParseIP("198.51.100.0"),
+	})
+
+	tests := []struct {
+		name    string
+		config  ecsConfig
+		addr    net.IP
+		trusted bool
+	}{
+		{"disabled", ecsConfig{Trusted: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}, net.IPv4(127, 0, 0, 1), false},
+		{"trusted", ecsConfig{Enabled: true, Trusted: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}, net.IPv4(127, 0, 0, 1), true},
+		{"untrusted", ecsConfig{Enabled: true, Trusted: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}, net.IPv4(192, 0, 2, 1), false},
+		{"empty trusted", ecsConfig{Enabled: true}, net.IPv4(127, 0, 0, 1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{config: &Config{ECS: tt.config}}
+
+			if got := s.clientSubnet(tt.addr, req) != nil; got != tt.trusted {
+				t.Errorf("clientSubnet() used = %v, want %v", got, tt.trusted)
+			}
+		})
+	}
+}
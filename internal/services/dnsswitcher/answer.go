@@ -0,0 +1,305 @@
+package dnsswitcher
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+func parseDNSAnswer(name string, qtype uint16, destination string, ttl uint32) []dns.RR {
+	var answers []dns.RR
+
+	if addr := net.ParseIP(destination); addr != nil {
+		switch {
+		case addr.To4() != nil:
+			if qtype == dns.TypeA {
+				answers = append(answers,
+					makeDNSAnswerA(name, addr.To4(), ttl),
+				)
+			}
+
+		case qtype == dns.TypeAAAA:
+			answers = append(answers,
+				makeDNSAnswerAAAA(name, addr, ttl),
+			)
+		}
+	} else {
+		answers = append(answers,
+			makeDNSAnswerCNAME(name, destination, ttl),
+		)
+	}
+
+	return answers
+}
+
+func makeDNSAnswer(name string, qtype uint16, config *dnsAnswer, ttl uint32, expand func(string) string) []dns.RR {
+	var answers []dns.RR
+
+	switch qtype {
+	case dns.TypeA:
+		for _, addr := range config.A {
+			answers = append(answers,
+				makeDNSAnswerA(name, addr, ttl),
+			)
+		}
+
+	case dns.TypeAAAA:
+		for _, addr := range config.AAAA {
+			answers = append(answers,
+				makeDNSAnswerAAAA(name, addr, ttl),
+			)
+		}
+
+	case dns.TypeCNAME:
+		if config.CNAME != "" {
+			answers = append(answers,
+				makeDNSAnswerCNAME(name, expand(config.CNAME), ttl),
+			)
+		}
+
+	case dns.TypeHTTPS:
+		if config.HTTPS != nil {
+			https := *config.HTTPS
+			https.Target = expand(https.Target)
+
+			answers = append(answers,
+				makeDNSAnswerHTTPS(name, &https, ttl),
+			)
+		}
+
+	case dns.TypeMX:
+		for i := range config.MX {
+			answers = append(answers,
+				makeDNSAnswerMX(name, &config.MX[i], ttl),
+			)
+		}
+
+	case dns.TypeTXT:
+		for _, text := range config.TXT {
+			answers = append(answers,
+				makeDNSAnswerTXT(name, text, ttl),
+			)
+		}
+
+	case dns.TypeSRV:
+		for i := range config.SRV {
+			answers = append(answers,
+				makeDNSAnswerSRV(name, &config.SRV[i], ttl),
+			)
+		}
+
+	case dns.TypeNS:
+		for _, target := range config.NS {
+			answers = append(answers,
+				makeDNSAnswerNS(name, target, ttl),
+			)
+		}
+
+	case dns.TypePTR:
+		for _, target := range config.PTR {
+			answers = append(answers,
+				makeDNSAnswerPTR(name, target, ttl),
+			)
+		}
+
+	case dns.TypeCAA:
+		for i := range config.CAA {
+			answers = append(answers,
+				makeDNSAnswerCAA(name, &config.CAA[i], ttl),
+			)
+		}
+	}
+
+	return answers
+}
+
+func makeDNSAnswerA(name string, addr net.IP, ttl uint32) *dns.A {
+	const rdLength = 4
+
+	return &dns.A{
+		Hdr: dns.RR_Header{
+			Name:     dns.Fqdn(name),
+			Rrtype:   dns.TypeA,
+			Class:    dns.ClassINET,
+			Ttl:      ttl,
+			Rdlength: rdLength,
+		},
+		A: addr,
+	}
+}
+
+func makeDNSAnswerAAAA(name string, addr net.IP, ttl uint32) *dns.AAAA {
+	const rdLength = 16
+
+	return &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:     dns.Fqdn(name),
+			Rrtype:   dns.TypeAAAA,
+			Class:    dns.ClassINET,
+			Ttl:      ttl,
+			Rdlength: rdLength,
+		},
+		AAAA: addr,
+	}
+}
+
+func makeDNSAnswerCNAME(name string, target string, ttl uint32) *dns.CNAME {
+	return &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Target: dns.Fqdn(target),
+	}
+}
+
+func makeDNSAnswerHTTPS(name string, config *dnsHTTPSAnswer, ttl uint32) *dns.HTTPS {
+	answer := &dns.HTTPS{
+		SVCB: dns.SVCB{
+			Hdr: dns.RR_Header{
+				Name:   dns.Fqdn(name),
+				Rrtype: dns.TypeHTTPS,
+				Class:  dns.ClassINET,
+				Ttl:    ttl,
+			},
+			Priority: config.Priority,
+			Target:   dns.Fqdn(config.Target),
+		},
+	}
+
+	if config.ALPN != nil {
+		answer.Value = append(answer.Value,
+			&dns.SVCBAlpn{Alpn: config.ALPN},
+		)
+	}
+
+	if config.IPv4Hint != nil {
+		answer.Value = append(answer.Value,
+			&dns.SVCBIPv4Hint{Hint: config.IPv4Hint},
+		)
+	}
+
+	if config.IPv6Hint != nil {
+		answer.Value = append(answer.Value,
+			&dns.SVCBIPv6Hint{Hint: config.IPv6Hint},
+		)
+	}
+
+	return answer
+}
+
+func makeDNSAnswerMX(name string, config *dnsMXAnswer, ttl uint32) *dns.MX {
+	return &dns.MX{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeMX,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Preference: config.Preference,
+		Mx:         dns.Fqdn(config.Exchange),
+	}
+}
+
+func makeDNSAnswerTXT(name string, text string, ttl uint32) *dns.TXT {
+	const maxLength = 255
+
+	var txt []string
+
+	for len(text) > maxLength {
+		txt = append(txt, text[:maxLength])
+		text = text[maxLength:]
+	}
+
+	txt = append(txt, text)
+
+	return &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Txt: txt,
+	}
+}
+
+func makeDNSAnswerSRV(name string, config *dnsSRVAnswer, ttl uint32) *dns.SRV {
+	return &dns.SRV{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeSRV,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Priority: config.Priority,
+		Weight:   config.Weight,
+		Port:     config.Port,
+		Target:   dns.Fqdn(config.Target),
+	}
+}
+
+func makeDNSAnswerNS(name string, target string, ttl uint32) *dns.NS {
+	return &dns.NS{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeNS,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Ns: dns.Fqdn(target),
+	}
+}
+
+func makeDNSAnswerPTR(name string, target string, ttl uint32) *dns.PTR {
+	return &dns.PTR{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypePTR,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Ptr: dns.Fqdn(target),
+	}
+}
+
+func makeDNSAnswerCAA(name string, config *dnsCAAAnswer, ttl uint32) *dns.CAA {
+	return &dns.CAA{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeCAA,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Flag:  config.Flag,
+		Tag:   config.Tag,
+		Value: config.Value,
+	}
+}
+
+func makeDNSAnswerSOA(zone string, ttl uint32) *dns.SOA {
+	const (
+		serial  = 1
+		refresh = 3600
+		retry   = 600
+		expire  = 86400
+	)
+
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(zone),
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Ns:      dns.Fqdn(zone),
+		Mbox:    dns.Fqdn("hostmaster." + zone),
+		Serial:  serial,
+		Refresh: refresh,
+		Retry:   retry,
+		Expire:  expire,
+		Minttl:  ttl,
+	}
+}
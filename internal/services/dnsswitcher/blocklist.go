@@ -0,0 +1,185 @@
+package dnsswitcher
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	"masquerade-dns/internal/pkg/pattern"
+)
+
+const (
+	blockNXDomain = "nxdomain"
+	blockRefused  = "refused"
+	blockSink     = "sink"
+)
+
+const (
+	formatHosts   = "hosts"
+	formatAdblock = "adblock"
+)
+
+const (
+	defaultBlocklistTTL    = 300
+	maxBlocklistLineLength = 64 * 1024
+)
+
+type blocklistConfig struct {
+	Name   string `env-required:"true" yaml:"name"`
+	Path   string `env-required:"true" yaml:"path"`
+	Format string `env-required:"true" yaml:"format"`
+	Block  string `yaml:"block"`
+	TTL    uint32 `yaml:"ttl"`
+}
+
+var hostsIgnoredNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+}
+
+func loadBlocklist(config *blocklistConfig, insert func(source string) error) error {
+	var parse func(line string) []string
+
+	switch config.Format {
+	case formatHosts:
+		parse = parseHostsLine
+
+	case formatAdblock:
+		parse = parseAdblockLine
+
+	default:
+		return errors.Errorf("blocklist format %q is not supported", config.Format)
+	}
+
+	file, err := os.Open(config.Path)
+	if err != nil {
+		return errors.Wrapf(err, "can't open blocklist %q", config.Path)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxBlocklistLineLength)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		for _, source := range parse(line) {
+			if err := insert(source); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrapf(err, "can't read blocklist %q", config.Path)
+	}
+
+	return nil
+}
+
+func parseHostsLine(line string) []string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+
+	if len(fields) == 0 || net.ParseIP(fields[0]) == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(fields)-1)
+
+	for _, name := range fields[1:] {
+		name = strings.ToLower(name)
+
+		if hostsIgnoredNames[name] || net.ParseIP(name) != nil {
+			continue
+		}
+
+		if _, ok := dns.IsDomainName(name); !ok {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func parseAdblockLine(line string) []string {
+	const (
+		prefix    = "||"
+		separator = "^"
+	)
+
+	if !strings.HasPrefix(line, prefix) {
+		return nil
+	}
+
+	line = line[len(prefix):]
+
+	i := strings.Index(line, separator)
+	if i < 0 {
+		return nil
+	}
+
+	name, options := line[:i], line[i+len(separator):]
+
+	if options != "" && !strings.HasPrefix(options, "$important") {
+		return nil
+	}
+
+	if strings.ContainsAny(name, "*/") {
+		return nil
+	}
+
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil
+	}
+
+	return []string{pattern.SuffixPrefix + strings.ToLower(name)}
+}
+
+func makeBlockAnswer(name string, qtype uint16, action string, ttl uint32) ([]dns.RR, int) {
+	switch action {
+	case blockNXDomain:
+		return nil, dns.RcodeNameError
+
+	case blockRefused:
+		return nil, dns.RcodeRefused
+	}
+
+	switch qtype {
+	case dns.TypeA:
+		return []dns.RR{makeDNSAnswerA(name, net.IPv4zero.To4(), ttl)}, dns.RcodeSuccess
+
+	case dns.TypeAAAA:
+		return []dns.RR{makeDNSAnswerAAAA(name, net.IPv6zero, ttl)}, dns.RcodeSuccess
+	}
+
+	return nil, dns.RcodeSuccess
+}
+
+func isValidBlock(action string) bool {
+	switch action {
+	case blockNXDomain, blockRefused, blockSink:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package dnsswitcher
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"masquerade-dns/internal/pkg/pattern"
+)
+
+func TestParseHostsLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		names []string
+	}{
+		{line: "", names: nil},
+		{line: "# 0.0.0.0 ads.example.com", names: nil},
+		{line: "0.0.0.0 ads.example.com", names: []string{"ads.example.com"}},
+		{line: "127.0.0.1 Ads.Example.com tracker.example.com", names: []string{"ads.example.com", "tracker.example.com"}},
+		{line: "0.0.0.0 ads.example.com # inline comment", names: []string{"ads.example.com"}},
+		{line: "0.0.0.0 ads.example.com#tracker.example.com", names: []string{"ads.example.com"}},
+		{line: "::1 localhost ip6-localhost", names: nil},
+		{line: "0.0.0.0 192.0.2.1 ads.example.com", names: []string{"ads.example.com"}},
+		{line: "ads.example.com", names: nil},
+		{line: "not-an-ip ads.example.com", names: nil},
+	}
+
+	for _, test := range tests {
+		names := parseHostsLine(test.line)
+
+		if !slices.Equal(names, test.names) {
+			t.Errorf("parseHostsLine(%q) = %q, want %q", test.line, names, test.names)
+		}
+	}
+}
+
+func TestParseAdblockLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		names []string
+	}{
+		{line: "", names: nil},
+		{line: "! comment", names: nil},
+		{line: "[Adblock Plus 2.0]", names: nil},
+		{line: "||ads.example.com^", names: []string{".ads.example.com"}},
+		{line: "||Ads.Example.com^", names: []string{".ads.example.com"}},
+		{line: "||ads.example.com^$important", names: []string{".ads.example.com"}},
+		{line: "||ads.example.com^$third-party", names: nil},
+		{line: "||ads.example.com^$important,third-party", names: []string{".ads.example.com"}},
+		{line: "||*.ads.example.com^", names: nil},
+		{line: "||ads*.example.com^", names: nil},
+		{line: "||ads.example.com/banner^", names: nil},
+		{line: "||ads.example.com", names: nil},
+		{line: "@@||ads.example.com^", names: nil},
+		{line: "/ads[0-9]+\\.example\\.com/", names: nil},
+	}
+
+	for _, test := range tests {
+		names := parseAdblockLine(test.line)
+
+		if !slices.Equal(names, test.names) {
+			t.Errorf("parseAdblockLine(%q) = %q, want %q", test.line, names, test.names)
+		}
+	}
+}
+
+func TestBlocklistAuthorityOwner(t *testing.T) {
+	idx := &index{
+		exact: make(map[string][]*rule),
+		trie:  newTrieNode(),
+	}
+
+	config := &switchConfig{Source: "test", Block: blockNXDomain, TTL: defaultBlocklistTTL}
+
+	for _, source := range []string{"ads.example.com", ".tracker.example.com"} {
+		p, err := pattern.Parse(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		idx.insert(p, &rule{config: config, zone: p.Name, blocklist: "test"})
+	}
+
+	tests := []struct {
+		name  string
+		owner string
+	}{
+		{name: "ads.example.com.", owner: "ads.example.com."},
+		{name: "a.b.tracker.example.com.", owner: "tracker.example.com."},
+	}
+
+	for _, test := range tests {
+		rule := idx.find(test.name, func(*rule) bool { return true })
+		if rule == nil {
+			t.Fatalf("no rule for %q", test.name)
+		}
+
+		question := dns.Question{Name: test.name, Qtype: dns.TypeA, Qclass: dns.ClassINET}
+
+		answer, rcode := rule.answer(question, nil)
+		if len(answer) != 0 || rcode != dns.RcodeNameError {
+			t.Errorf("answer for %q = %v, %d, want NXDOMAIN", test.name, answer, rcode)
+		}
+
+		if owner := rule.authority(question).Header().Name; owner != test.owner {
+			t.Errorf("SOA owner for %q = %q, want %q", test.name, owner, test.owner)
+		}
+	}
+}
@@ -3,19 +3,29 @@ package dnsswitcher
 import (
 	"context"
 	"net"
-	"regexp"
-	"strings"
+	"net/netip"
+	"time"
 
 	"github.com/miekg/dns"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
 	"masquerade-dns/internal/metrics"
 	"masquerade-dns/internal/pkg/logger"
+	"masquerade-dns/internal/pkg/pattern"
+	"masquerade-dns/internal/pkg/subnet"
 	"masquerade-dns/internal/pkg/trace"
+	"masquerade-dns/internal/pkg/ttlpolicy"
 )
 
+const maxFlattenDepth = 8
+
 type dnsLimiter interface {
-	Limit(addr net.IP, source string, maxCount int) bool
+	Limit(addr net.IP, source string, maxCount int, prefix subnet.Config) bool
+}
+
+type dnsResolver interface {
+	Lookup(ctx context.Context, req *dns.Msg) *dns.Msg
 }
 
 type dnsHTTPSAnswer struct {
@@ -26,44 +36,110 @@ type dnsHTTPSAnswer struct {
 	IPv6Hint []net.IP `yaml:"ipv6hint"`
 }
 
+type dnsMXAnswer struct {
+	Preference uint16 `yaml:"preference"`
+	Exchange   string `env-required:"true" yaml:"exchange"`
+}
+
+type dnsSRVAnswer struct {
+	Priority uint16 `yaml:"priority"`
+	Weight   uint16 `yaml:"weight"`
+	Port     uint16 `env-required:"true" yaml:"port"`
+	Target   string `env-required:"true" yaml:"target"`
+}
+
+type dnsCAAAnswer struct {
+	Flag  uint8  `yaml:"flag"`
+	Tag   string `env-required:"true" yaml:"tag"`
+	Value string `env-required:"true" yaml:"value"`
+}
+
 type dnsAnswer struct {
-	A     net.IP          `yaml:"a"`
-	AAAA  net.IP          `yaml:"aaaa"`
+	A     addrList        `yaml:"a"`
+	AAAA  addrList        `yaml:"aaaa"`
 	CNAME string          `yaml:"cname"`
 	HTTPS *dnsHTTPSAnswer `yaml:"https"`
+	MX    []dnsMXAnswer   `yaml:"mx"`
+	TXT   []string        `yaml:"txt"`
+	SRV   []dnsSRVAnswer  `yaml:"srv"`
+	NS    []string        `yaml:"ns"`
+	PTR   []string        `yaml:"ptr"`
+	CAA   []dnsCAAAnswer  `yaml:"caa"`
+	Order string          `yaml:"order"`
 }
 
 type switchConfig struct {
-	Source      string     `env-required:"true" yaml:"source"`
-	Destination string     `yaml:"destination"`
-	Answer      *dnsAnswer `yaml:"answer"`
-	MaxCount    int        `env-required:"true" yaml:"maxCount"`
-	TTL         uint32     `env-required:"true" yaml:"ttl"`
+	Name        string            `yaml:"name"`
+	Source      string            `env-required:"true" yaml:"source"`
+	Destination string            `yaml:"destination"`
+	Answer      *dnsAnswer        `yaml:"answer"`
+	MaxCount    int               `env-required:"true" yaml:"maxCount"`
+	TTL         uint32            `env-required:"true" yaml:"ttl"`
+	Allow       []netip.Prefix    `yaml:"allow"`
+	Deny        []netip.Prefix    `yaml:"deny"`
+	ActiveFrom  time.Time         `yaml:"activeFrom"`
+	ActiveUntil time.Time         `yaml:"activeUntil"`
+	Windows     []windowConfig    `yaml:"windows"`
+	Fallthrough bool              `yaml:"fallthrough"`
+	Variants    []variantConfig   `yaml:"variants"`
+	Sticky      bool              `yaml:"sticky"`
+	Block       string            `yaml:"block"`
+	Flatten     bool              `yaml:"flatten"`
+	TTLPolicy   *ttlpolicy.Config `yaml:"ttlPolicy"`
+	Synthesize  bool              `yaml:"synthesize"`
+	OnLimit     *limitConfig      `yaml:"onLimit"`
+	LimitPrefix subnet.Config     `yaml:"limitPrefix"`
+}
+
+type zoneConfig struct {
+	Path    string `env-required:"true" yaml:"path"`
+	Origin  string `yaml:"origin"`
+	Flatten bool   `yaml:"flatten"`
 }
 
 type Config struct {
-	Settings []switchConfig `yaml:"settings"`
+	Settings   []switchConfig    `yaml:"settings"`
+	Zones      []zoneConfig      `yaml:"zones"`
+	Blocklists []blocklistConfig `yaml:"blocklists"`
+	PTR        bool              `yaml:"ptr"`
 }
 
 type Service struct {
-	config  *Config
-	metrics *metrics.Metrics
-	limiter dnsLimiter
-	logger  *zap.SugaredLogger
+	config   *Config
+	metrics  *metrics.Metrics
+	limiter  dnsLimiter
+	resolver dnsResolver
+	logger   *zap.SugaredLogger
+
+	index *index
 }
 
 func NewService(
 	config *Config,
 	metrics *metrics.Metrics,
 	limiter dnsLimiter,
+	resolver dnsResolver,
 	logger *zap.SugaredLogger,
-) *Service {
-	return &Service{
-		config:  config,
-		metrics: metrics,
-		limiter: limiter,
-		logger:  logger,
+) (*Service, error) {
+	index, err := newIndex(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't build switch rules index")
 	}
+
+	for _, setting := range config.Settings {
+		if setting.Name != "" {
+			metrics.InitSwitchRule(setting.Name)
+		}
+	}
+
+	return &Service{
+		config:   config,
+		metrics:  metrics,
+		limiter:  limiter,
+		resolver: resolver,
+		logger:   logger,
+		index:    index,
+	}, nil
 }
 
 func (s *Service) Switch(ctx context.Context, addr net.IP, req *dns.Msg) (*dns.Msg, bool) {
@@ -77,191 +153,169 @@ func (s *Service) Switch(ctx context.Context, addr net.IP, req *dns.Msg) (*dns.M
 
 	question := req.Question[0]
 
-	for _, config := range s.config.Settings {
-		if strings.HasPrefix(config.Source, "/") && strings.HasSuffix(config.Source, "/") {
-			source := strings.Trim(config.Source, "/")
-
-			r, err := regexp.Compile(source)
-			if err != nil {
-				s.logger.Warnw(
-					"Can't compile regular expression",
-					logger.TraceID(traceID),
-					logger.Error(err),
-				)
+	now := time.Now()
 
-				continue
-			}
+	rule := s.find(question.Name, addr, now)
+	if rule == nil {
+		return nil, false
+	}
 
-			if !r.MatchString(question.Name) {
-				continue
-			}
-		} else if dns.Fqdn(config.Source) != question.Name {
-			continue
-		}
+	config := rule.config
 
-		if s.limiter.Limit(addr, config.Source, config.MaxCount) {
-			s.logger.Infow("Limit DNS request", logger.TraceID(traceID))
+	answer, rcode := rule.answer(question, addr)
 
-			s.metrics.IncLimitedDNSRequests()
+	if rcode == dns.RcodeSuccess && len(answer) == 0 && config.Fallthrough {
+		s.logger.Infow("Fall through DNS request", logger.TraceID(traceID), "rule", rule.name())
 
-			return nil, false
+		if label := rule.label(); label != "" {
+			s.metrics.IncSwitchRuleFallthroughs(label)
 		}
 
-		s.logger.Infow("Switch DNS request", logger.TraceID(traceID))
-
-		s.metrics.IncSwitchedDNSRequests(addr)
-
-		resp := &dns.Msg{}
-		resp.SetReply(req)
+		return nil, false
+	}
 
-		var answer []dns.RR
+	if s.limiter.Limit(addr, config.Source, config.MaxCount, config.LimitPrefix) {
+		s.logger.Infow("Limit DNS request", logger.TraceID(traceID), "rule", rule.name())
 
-		if config.Destination != "" {
-			answer = parseDNSAnswer(question.Name, config.Destination, config.TTL)
-		} else {
-			answer = makeDNSAnswer(question.Name, question.Qtype, config.Answer, config.TTL)
+		s.metrics.IncLimitedDNSRequests()
+		if label := rule.label(); label != "" {
+			s.metrics.IncSwitchRuleLimits(label)
 		}
 
-		resp.Answer = append(resp.Answer, answer...)
+		return s.limited(req, question, addr, rule)
+	}
 
-		return resp, true
+	if label := rule.label(); label != "" {
+		s.metrics.IncSwitchRuleHits(label)
 	}
 
-	return nil, false
-}
+	if config.Block != "" {
+		s.logger.Infow("Block DNS request", logger.TraceID(traceID), "rule", rule.name())
 
-func parseDNSAnswer(name, destination string, ttl uint32) []dns.RR {
-	var answers []dns.RR
+		s.metrics.IncBlockedDNSRequests(rule.name())
+	} else {
+		s.logger.Infow("Switch DNS request", logger.TraceID(traceID), "rule", rule.name())
 
-	if addr := net.ParseIP(destination); addr != nil {
-		switch len(addr) {
-		case net.IPv4len:
-			answers = append(answers,
-				makeDNSAnswerA(name, addr, ttl),
-			)
+		s.metrics.IncSwitchedDNSRequests(addr)
+	}
 
-		case net.IPv6len:
-			answers = append(answers,
-				makeDNSAnswerAAAA(name, addr, ttl),
-			)
-		}
-	} else {
-		answers = append(answers,
-			makeDNSAnswerCNAME(name, destination, ttl),
-		)
+	if config.Flatten && rcode == dns.RcodeSuccess {
+		answer = s.flatten(ctx, addr, now, question, answer)
 	}
 
-	return answers
+	return reply(req, question, rule, answer, rcode), true
 }
 
-func makeDNSAnswer(name string, qtype uint16, config *dnsAnswer, ttl uint32) []dns.RR {
-	var answers []dns.RR
+func (s *Service) limited(req *dns.Msg, question dns.Question, addr net.IP, rule *rule) (*dns.Msg, bool) {
+	if rule.config.OnLimit == nil {
+		return nil, false
+	}
 
-	switch qtype {
-	case dns.TypeA:
-		if config.A != nil {
-			answers = append(answers,
-				makeDNSAnswerA(name, config.A, ttl),
-			)
-		}
+	switch rule.config.OnLimit.Action {
+	case limitRefused:
+		return reply(req, question, rule, nil, dns.RcodeRefused), true
 
-	case dns.TypeAAAA:
-		if config.AAAA != nil {
-			answers = append(answers,
-				makeDNSAnswerAAAA(name, config.AAAA, ttl),
-			)
-		}
+	case limitNXDomain:
+		return reply(req, question, rule, nil, dns.RcodeNameError), true
 
-	case dns.TypeCNAME:
-		if config.CNAME != "" {
-			answers = append(answers,
-				makeDNSAnswerCNAME(name, config.CNAME, ttl),
-			)
-		}
-	case dns.TypeHTTPS:
-		if config.HTTPS != nil {
-			answers = append(answers,
-				makeDNSAnswerHTTPS(name, config.HTTPS, ttl),
-			)
-		}
-	}
+	case limitDrop:
+		return nil, true
 
-	return answers
-}
+	case limitAnswer:
+		answer := rule.limitVariant.makeAnswer(question, rule.config.TTL, rule.expander(question.Name))
 
-func makeDNSAnswerA(name string, addr net.IP, ttl uint32) *dns.A {
-	const rdLength = 4
-
-	return &dns.A{
-		Hdr: dns.RR_Header{
-			Name:     dns.Fqdn(name),
-			Rrtype:   dns.TypeA,
-			Class:    dns.ClassINET,
-			Ttl:      ttl,
-			Rdlength: rdLength,
-		},
-		A: addr,
+		return reply(req, question, rule, answer, dns.RcodeSuccess), true
+
+	default:
+		return nil, false
 	}
 }
 
-func makeDNSAnswerAAAA(name string, addr net.IP, ttl uint32) *dns.AAAA {
-	const rdLength = 16
-
-	return &dns.AAAA{
-		Hdr: dns.RR_Header{
-			Name:     dns.Fqdn(name),
-			Rrtype:   dns.TypeAAAA,
-			Class:    dns.ClassINET,
-			Ttl:      ttl,
-			Rdlength: rdLength,
-		},
-		AAAA: addr,
+func reply(req *dns.Msg, question dns.Question, rule *rule, answer []dns.RR, rcode int) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetRcode(req, rcode)
+
+	resp.Answer = append(resp.Answer, answer...)
+
+	if rcode == dns.RcodeNameError || (rcode == dns.RcodeSuccess && len(answer) == 0) {
+		resp.Ns = append(resp.Ns, rule.authority(question))
 	}
+
+	rule.config.TTLPolicy.Apply(resp.Answer, resp.Ns)
+
+	return resp
 }
 
-func makeDNSAnswerCNAME(name string, target string, ttl uint32) *dns.CNAME {
-	return &dns.CNAME{
-		Hdr: dns.RR_Header{
-			Name:   dns.Fqdn(name),
-			Rrtype: dns.TypeCNAME,
-			Class:  dns.ClassINET,
-			Ttl:    ttl,
-		},
-		Target: dns.Fqdn(target),
-	}
+func (s *Service) find(name string, addr net.IP, now time.Time) *rule {
+	return s.index.find(name, func(r *rule) bool {
+		return r.active(now) && r.allowed(addr)
+	})
 }
 
-func makeDNSAnswerHTTPS(name string, config *dnsHTTPSAnswer, ttl uint32) *dns.HTTPS {
-	answer := &dns.HTTPS{
-		SVCB: dns.SVCB{
-			Hdr: dns.RR_Header{
-				Name:   dns.Fqdn(name),
-				Rrtype: dns.TypeHTTPS,
-				Class:  dns.ClassINET,
-				Ttl:    ttl,
-			},
-			Priority: config.Priority,
-			Target:   dns.Fqdn(config.Target),
-		},
-	}
+func (s *Service) flatten(
+	ctx context.Context,
+	addr net.IP,
+	now time.Time,
+	question dns.Question,
+	answer []dns.RR,
+) []dns.RR {
+	traceID := trace.UnpackTraceID(ctx)
 
-	if config.ALPN != nil {
-		answer.Value = append(answer.Value,
-			&dns.SVCBAlpn{Alpn: config.ALPN},
-		)
+	if question.Qtype == dns.TypeCNAME {
+		return answer
 	}
 
-	if config.IPv4Hint != nil {
-		answer.Value = append(answer.Value,
-			&dns.SVCBIPv4Hint{Hint: config.IPv4Hint},
-		)
+	visited := map[string]bool{
+		pattern.CanonicalName(question.Name): true,
 	}
 
-	if config.IPv6Hint != nil {
-		answer.Value = append(answer.Value,
-			&dns.SVCBIPv6Hint{Hint: config.IPv6Hint},
-		)
+	for range maxFlattenDepth {
+		if len(answer) == 0 {
+			return answer
+		}
+
+		cname, ok := answer[len(answer)-1].(*dns.CNAME)
+		if !ok {
+			return answer
+		}
+
+		if visited[pattern.CanonicalName(cname.Target)] {
+			s.logger.Warnw("Detect CNAME loop", logger.TraceID(traceID), "target", cname.Target)
+
+			return answer
+		}
+
+		visited[pattern.CanonicalName(cname.Target)] = true
+
+		next := dns.Question{
+			Name:   cname.Target,
+			Qtype:  question.Qtype,
+			Qclass: question.Qclass,
+		}
+
+		if rule := s.find(next.Name, addr, now); rule != nil {
+			records, rcode := rule.answer(next, addr)
+			if rcode != dns.RcodeSuccess || len(records) == 0 {
+				return answer
+			}
+
+			answer = append(answer, records...)
+
+			continue
+		}
+
+		req := &dns.Msg{}
+		req.SetQuestion(next.Name, next.Qtype)
+
+		resp := s.resolver.Lookup(ctx, req)
+		if resp.Rcode != dns.RcodeSuccess {
+			return answer
+		}
+
+		return append(answer, resp.Answer...)
 	}
 
+	s.logger.Warnw("Exceed CNAME chain depth", logger.TraceID(traceID), "name", question.Name)
+
 	return answer
 }
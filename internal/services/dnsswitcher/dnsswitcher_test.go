@@ -0,0 +1,175 @@
+package dnsswitcher
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"masquerade-dns/internal/metrics"
+	"masquerade-dns/internal/pkg/subnet"
+	"masquerade-dns/internal/pkg/trace"
+)
+
+var testMetrics = metrics.NewMetrics()
+
+type stubLimiter struct{}
+
+func (stubLimiter) Limit(net.IP, string, int, subnet.Config) bool {
+	return false
+}
+
+type stubResolver struct {
+	answers map[string][]dns.RR
+}
+
+func (r *stubResolver) Lookup(_ context.Context, req *dns.Msg) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetReply(req)
+	resp.Answer = r.answers[req.Question[0].Name]
+
+	return resp
+}
+
+func newTestService(t *testing.T, config *Config, resolver dnsResolver) *Service {
+	t.Helper()
+
+	return newTestServiceWithLogger(t, config, resolver, zap.NewNop().Sugar())
+}
+
+func newTestServiceWithLogger(t *testing.T, config *Config, resolver dnsResolver, logger *zap.SugaredLogger) *Service {
+	t.Helper()
+
+	service, err := NewService(config, testMetrics, stubLimiter{}, resolver, logger)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	return service
+}
+
+func TestSwitchFlatten(t *testing.T) {
+	resolver := &stubResolver{
+		answers: map[string][]dns.RR{
+			"target.test.": {makeDNSAnswerA("target.test.", net.IPv4(192, 0, 2, 1).To4(), 60)},
+		},
+	}
+
+	service := newTestService(t, &Config{
+		Settings: []switchConfig{
+			{Source: "destination.test", Destination: "target.test.", TTL: 60, Flatten: true},
+			{Source: "answer.test", Answer: &dnsAnswer{CNAME: "target.test."}, TTL: 60, Flatten: true},
+			{Source: "plain.test", Answer: &dnsAnswer{CNAME: "target.test."}, TTL: 60},
+		},
+	}, resolver)
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		want  []uint16
+	}{
+		{"destination.test.", dns.TypeA, []uint16{dns.TypeCNAME, dns.TypeA}},
+		{"answer.test.", dns.TypeA, []uint16{dns.TypeCNAME, dns.TypeA}},
+		{"answer.test.", dns.TypeCNAME, []uint16{dns.TypeCNAME}},
+		{"plain.test.", dns.TypeA, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+dns.TypeToString[tt.qtype], func(t *testing.T) {
+			req := &dns.Msg{}
+			req.SetQuestion(tt.name, tt.qtype)
+
+			resp, ok := service.Switch(trace.PackTraceID(context.Background(), trace.NewTraceID()), net.IPv4(127, 0, 0, 1), req)
+			if !ok {
+				t.Fatal("request is not switched")
+			}
+
+			var got []uint16
+			for _, rr := range resp.Answer {
+				got = append(got, rr.Header().Rrtype)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("answer types = %v, want %v", got, tt.want)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("answer types = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSwitchFlattenNoData(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	service := newTestServiceWithLogger(t, &Config{
+		Settings: []switchConfig{
+			{Source: "chain.test", Answer: &dnsAnswer{CNAME: "nodata.test."}, TTL: 60, Flatten: true},
+			{Source: "nodata.test", Answer: &dnsAnswer{A: addrList{net.IPv4(192, 0, 2, 1)}}, TTL: 60},
+		},
+	}, &stubResolver{}, zap.New(core).Sugar())
+
+	req := &dns.Msg{}
+	req.SetQuestion("chain.test.", dns.TypeAAAA)
+
+	resp, ok := service.Switch(trace.PackTraceID(context.Background(), trace.NewTraceID()), net.IPv4(127, 0, 0, 1), req)
+	if !ok {
+		t.Fatal("request is not switched")
+	}
+
+	if len(resp.Answer) != 1 || resp.Answer[0].Header().Rrtype != dns.TypeCNAME {
+		t.Fatalf("answer = %v, want a single CNAME", resp.Answer)
+	}
+
+	if n := logs.FilterMessage("Detect CNAME loop").Len(); n != 0 {
+		t.Fatalf("CNAME loop is logged %d times for a NODATA target", n)
+	}
+}
+
+func TestRuleLabel(t *testing.T) {
+	idx, err := newIndex(&Config{
+		Settings: []switchConfig{
+			{Name: "named", Source: "named.test", Destination: "192.0.2.1", TTL: 60},
+			{Name: "named", Source: "alias.test", Destination: "192.0.2.1", TTL: 60},
+			{Name: "first", Source: "first.test", Destination: "192.0.2.4", TTL: 60},
+			{Name: "second", Source: "second.test", Destination: "192.0.2.4", TTL: 60},
+			{Source: "unnamed.test", Destination: "192.0.2.2", TTL: 60},
+			{Source: "/^re-.*\\.test\\.$/", Destination: "192.0.2.3", TTL: 60},
+		},
+		PTR: true,
+	})
+	if err != nil {
+		t.Fatalf("newIndex() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		label string
+	}{
+		{"named.test.", "named"},
+		{"unnamed.test.", ""},
+		{"re-1.test.", ""},
+		{"1.2.0.192.in-addr.arpa.", "named/ptr"},
+		{"2.2.0.192.in-addr.arpa.", ""},
+		{"4.2.0.192.in-addr.arpa.", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := idx.find(tt.name, func(*rule) bool { return true })
+			if r == nil {
+				t.Fatal("rule is not found")
+			}
+
+			if got := r.label(); got != tt.label {
+				t.Errorf("label() = %q, want %q", got, tt.label)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package dnsswitcher
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	"masquerade-dns/internal/pkg/pattern"
+)
+
+type index struct {
+	exact   map[string][]*rule
+	trie    *trieNode
+	regexps []*rule
+
+	forward []*rule
+
+	regexpFilter *regexp.Regexp
+}
+
+func newIndex(config *Config) (*index, error) {
+	idx := &index{
+		exact: make(map[string][]*rule),
+		trie:  newTrieNode(),
+	}
+
+	for i := range config.Settings {
+		if err := idx.add(&config.Settings[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range config.Zones {
+		if err := idx.addZone(&config.Zones[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.PTR {
+		if err := idx.addReverse(idx.forward); err != nil {
+			return nil, err
+		}
+	}
+
+	idx.forward = nil
+
+	for i := range config.Blocklists {
+		if err := idx.addBlocklist(&config.Blocklists[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := idx.compileRegexpFilter(); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+func (idx *index) compileRegexpFilter() error {
+	if len(idx.regexps) == 0 {
+		return nil
+	}
+
+	sources := make([]string, 0, len(idx.regexps))
+
+	for _, rule := range idx.regexps {
+		sources = append(sources, "(?:"+rule.regexp.String()+")")
+	}
+
+	filter, err := regexp.Compile(strings.Join(sources, "|"))
+	if err != nil {
+		return errors.Wrap(err, "can't compile regular expressions filter")
+	}
+
+	idx.regexpFilter = filter
+
+	return nil
+}
+
+func (idx *index) add(config *switchConfig) error {
+	p, err := pattern.Parse(config.Source)
+	if err != nil {
+		return err
+	}
+
+	var variants []*variant
+
+	switch {
+	case config.Block != "":
+		if !isValidBlock(config.Block) {
+			return errors.Errorf("block action %q is not supported for %q", config.Block, config.Source)
+		}
+
+	case config.Synthesize:
+		if p.Kind != pattern.KindWildcard && p.Kind != pattern.KindSuffix {
+			return errors.Errorf("synthesis requires wildcard or suffix source for %q", config.Source)
+		}
+
+	default:
+		variants, err = newVariants(config)
+		if err != nil {
+			return errors.Wrapf(err, "can't parse answer for %q", config.Source)
+		}
+	}
+
+	for _, prefix := range slices.Concat(config.Allow, config.Deny) {
+		if !prefix.IsValid() {
+			return errors.Errorf("client prefix is not valid for %q", config.Source)
+		}
+	}
+
+	var limitVariant *variant
+
+	if config.OnLimit != nil {
+		if !isValidLimitAction(config.OnLimit.Action) {
+			return errors.Errorf("limit action %q is not supported for %q", config.OnLimit.Action, config.Source)
+		}
+
+		if config.OnLimit.Action == limitAnswer {
+			limitVariant, err = newVariant(1, config.OnLimit.Destination, config.OnLimit.Answer)
+			if err != nil {
+				return errors.Wrapf(err, "can't parse limit answer for %q", config.Source)
+			}
+		}
+	}
+
+	if err := config.LimitPrefix.Validate(); err != nil {
+		return errors.Wrapf(err, "can't parse limit prefix for %q", config.Source)
+	}
+
+	if config.TTLPolicy != nil {
+		if err := config.TTLPolicy.Validate(); err != nil {
+			return errors.Wrapf(err, "can't parse TTL policy for %q", config.Source)
+		}
+	}
+
+	if !config.ActiveFrom.IsZero() && !config.ActiveUntil.IsZero() && !config.ActiveFrom.Before(config.ActiveUntil) {
+		return errors.Errorf("active period is empty for %q", config.Source)
+	}
+
+	windows := make([]*window, 0, len(config.Windows))
+
+	for i := range config.Windows {
+		window, err := newWindow(&config.Windows[i])
+		if err != nil {
+			return errors.Wrapf(err, "can't parse active window for %q", config.Source)
+		}
+
+		windows = append(windows, window)
+	}
+
+	rule := &rule{
+		config:   config,
+		windows:  windows,
+		variants: variants,
+		zone:     p.Name,
+
+		limitVariant: limitVariant,
+	}
+
+	idx.insert(p, rule)
+
+	if p.Kind == pattern.KindExact {
+		idx.forward = append(idx.forward, rule)
+	}
+
+	return nil
+}
+
+func (idx *index) addZone(config *zoneConfig) error {
+	records, err := loadZone(config)
+	if err != nil {
+		return err
+	}
+
+	soa := zoneSOA(records)
+	if soa == nil {
+		return errors.Errorf("zone file %q has no SOA record", config.Path)
+	}
+
+	origin := pattern.CanonicalName(soa.Hdr.Name)
+
+	names := make([]string, 0, len(records))
+
+	for name := range records {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		rrs := records[name]
+
+		p, err := pattern.Parse(name)
+		if err != nil {
+			return err
+		}
+
+		rule := &rule{
+			config:  &switchConfig{Source: name, Flatten: config.Flatten},
+			records: groupRecords(rrs),
+			zone:    p.Name,
+			soa:     soa,
+		}
+
+		idx.insert(p, rule)
+
+		if p.Kind == pattern.KindExact {
+			idx.forward = append(idx.forward, rule)
+		}
+	}
+
+	for _, name := range emptyNonTerminals(records, origin) {
+		rule := &rule{
+			config:  &switchConfig{Source: name},
+			records: map[uint16][]dns.RR{},
+			soa:     soa,
+		}
+
+		idx.insert(&pattern.Pattern{Kind: pattern.KindExact, Name: name}, rule)
+	}
+
+	rule := &rule{
+		config:   &switchConfig{Source: pattern.SuffixPrefix + origin},
+		soa:      soa,
+		nxdomain: true,
+	}
+
+	idx.insert(&pattern.Pattern{Kind: pattern.KindSuffix, Name: origin}, rule)
+
+	return nil
+}
+
+func (idx *index) addBlocklist(config *blocklistConfig) error {
+	block := config.Block
+	if block == "" {
+		block = blockNXDomain
+	}
+
+	if !isValidBlock(block) {
+		return errors.Errorf("block action %q is not supported for blocklist %q", block, config.Name)
+	}
+
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = defaultBlocklistTTL
+	}
+
+	listConfig := &switchConfig{
+		Source: config.Name,
+		Block:  block,
+		TTL:    ttl,
+	}
+
+	return loadBlocklist(config, func(source string) error {
+		p, err := pattern.Parse(source)
+		if err != nil {
+			return err
+		}
+
+		rule := &rule{
+			config:    listConfig,
+			zone:      p.Name,
+			blocklist: config.Name,
+		}
+
+		idx.insert(p, rule)
+
+		return nil
+	})
+}
+
+func (idx *index) insert(p *pattern.Pattern, rule *rule) {
+	rule.regexp = p.Regexp
+
+	switch p.Kind {
+	case pattern.KindRegexp:
+		idx.regexps = append(idx.regexps, rule)
+
+	case pattern.KindWildcard:
+		idx.trie.insertWildcard(p.Name, rule)
+
+	case pattern.KindSuffix:
+		idx.trie.insertSuffix(p.Name, rule)
+
+	case pattern.KindExact:
+		idx.exact[p.Name] = append(idx.exact[p.Name], rule)
+	}
+}
+
+func (idx *index) find(name string, accept func(r *rule) bool) *rule {
+	for _, rule := range idx.exact[pattern.CanonicalName(name)] {
+		if accept(rule) {
+			return rule
+		}
+	}
+
+	if rule := idx.trie.find(pattern.CanonicalName(name), accept); rule != nil {
+		return rule
+	}
+
+	if idx.regexpFilter == nil || !idx.regexpFilter.MatchString(name) {
+		return nil
+	}
+
+	for _, rule := range idx.regexps {
+		if rule.regexp.MatchString(name) && accept(rule) {
+			return rule
+		}
+	}
+
+	return nil
+}
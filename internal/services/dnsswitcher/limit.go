@@ -0,0 +1,24 @@
+package dnsswitcher
+
+const (
+	limitFallthrough = "fallthrough"
+	limitRefused     = "refused"
+	limitNXDomain    = "nxdomain"
+	limitDrop        = "drop"
+	limitAnswer      = "answer"
+)
+
+type limitConfig struct {
+	Action      string     `env-required:"true" yaml:"action"`
+	Destination string     `yaml:"destination"`
+	Answer      *dnsAnswer `yaml:"answer"`
+}
+
+func isValidLimitAction(action string) bool {
+	switch action {
+	case limitFallthrough, limitRefused, limitNXDomain, limitDrop, limitAnswer:
+		return true
+	}
+
+	return false
+}
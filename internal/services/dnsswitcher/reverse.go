@@ -0,0 +1,133 @@
+package dnsswitcher
+
+import (
+	"net"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"masquerade-dns/internal/pkg/pattern"
+)
+
+const ptrLabelSuffix = "/ptr"
+
+func (idx *index) addReverse(rules []*rule) error {
+	reverses := make(map[string]*rule)
+
+	for _, forward := range rules {
+		for _, addr := range forward.addrs() {
+			name, err := dns.ReverseAddr(addr.String())
+			if err != nil {
+				continue
+			}
+
+			if reverse, ok := reverses[name]; ok {
+				if !slices.Contains(reverse.forwards, forward) {
+					reverse.forwards = append(reverse.forwards, forward)
+					reverse.config.TTL = min(reverse.config.TTL, forward.ttl())
+
+					if reverse.config.Name != forward.config.Name+ptrLabelSuffix {
+						reverse.config.Name = ""
+					}
+				}
+
+				continue
+			}
+
+			p, err := pattern.Parse(name)
+			if err != nil {
+				return err
+			}
+
+			var label string
+			if forward.config.Name != "" {
+				label = forward.config.Name + ptrLabelSuffix
+			}
+
+			reverse := &rule{
+				config: &switchConfig{
+					Name:      label,
+					Source:    name,
+					TTL:       forward.ttl(),
+					TTLPolicy: forward.config.TTLPolicy,
+				},
+				forwards: []*rule{forward},
+				zone:     p.Name,
+			}
+
+			reverses[name] = reverse
+
+			idx.insert(p, reverse)
+		}
+	}
+
+	return nil
+}
+
+func (r *rule) reverseAnswer(question dns.Question, addr net.IP) []dns.RR {
+	if question.Qtype != dns.TypePTR {
+		return nil
+	}
+
+	now := time.Now()
+
+	var answer []dns.RR
+
+	for _, forward := range r.forwards {
+		if !forward.active(now) || !forward.allowed(addr) {
+			continue
+		}
+
+		answer = append(answer, makeDNSAnswerPTR(question.Name, forward.config.Source, r.config.TTL))
+	}
+
+	return answer
+}
+
+func (r *rule) ttl() uint32 {
+	if r.records != nil {
+		return r.recordsTTL()
+	}
+
+	return r.config.TTL
+}
+
+func (r *rule) addrs() []net.IP {
+	var addrs []net.IP
+
+	if r.records != nil {
+		for _, record := range r.records[dns.TypeA] {
+			addrs = append(addrs, record.(*dns.A).A)
+		}
+
+		for _, record := range r.records[dns.TypeAAAA] {
+			addrs = append(addrs, record.(*dns.AAAA).AAAA)
+		}
+
+		return addrs
+	}
+
+	for _, variant := range r.variants {
+		if addr := net.ParseIP(variant.destination); addr != nil {
+			addrs = append(addrs, addr)
+		}
+
+		if variant.answer != nil {
+			addrs = append(addrs, variant.answer.A...)
+			addrs = append(addrs, variant.answer.AAAA...)
+		}
+	}
+
+	return addrs
+}
+
+func (r *rule) recordsTTL() uint32 {
+	for _, rrtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		if records := r.records[rrtype]; len(records) != 0 {
+			return records[0].Header().Ttl
+		}
+	}
+
+	return 0
+}
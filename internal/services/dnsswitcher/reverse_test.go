@@ -0,0 +1,65 @@
+package dnsswitcher
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestReverseGroupsForwards(t *testing.T) {
+	addr := net.IPv4(192, 0, 2, 10)
+
+	idx, err := newIndex(&Config{
+		Settings: []switchConfig{
+			{Source: "one.test", Answer: &dnsAnswer{A: addrList{addr}}, TTL: 300},
+			{Source: "two.test", Answer: &dnsAnswer{A: addrList{addr}}, TTL: 60},
+			{
+				Source: "denied.test",
+				Answer: &dnsAnswer{A: addrList{addr}},
+				TTL:    60,
+				Deny:   []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			},
+		},
+		PTR: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := net.IPv4(127, 0, 0, 1)
+	name := "10.2.0.192.in-addr.arpa."
+
+	rule := idx.find(name, func(r *rule) bool {
+		return r.active(time.Now()) && r.allowed(client)
+	})
+	if rule == nil {
+		t.Fatalf("no reverse rule for %q", name)
+	}
+
+	question := dns.Question{Name: name, Qtype: dns.TypePTR, Qclass: dns.ClassINET}
+
+	answer, rcode := rule.answer(question, client)
+	if rcode != dns.RcodeSuccess {
+		t.Fatalf("rcode = %d, want %d", rcode, dns.RcodeSuccess)
+	}
+
+	var targets []string
+
+	for _, rr := range answer {
+		ptr := rr.(*dns.PTR)
+
+		if ptr.Hdr.Ttl != 60 {
+			t.Errorf("PTR %q TTL = %d, want 60", ptr.Ptr, ptr.Hdr.Ttl)
+		}
+
+		targets = append(targets, ptr.Ptr)
+	}
+
+	if want := []string{"one.test.", "two.test."}; !slices.Equal(targets, want) {
+		t.Fatalf("PTR targets = %q, want %q", targets, want)
+	}
+}
@@ -0,0 +1,219 @@
+package dnsswitcher
+
+import (
+	"net"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type addrList []net.IP
+
+func (l *addrList) UnmarshalYAML(unmarshal func(any) error) error {
+	var addr net.IP
+
+	if err := unmarshal(&addr); err == nil {
+		*l = addrList{addr}
+
+		return nil
+	}
+
+	var addrs []net.IP
+
+	if err := unmarshal(&addrs); err != nil {
+		return err
+	}
+
+	*l = addrs
+
+	return nil
+}
+
+type rule struct {
+	config    *switchConfig
+	regexp    *regexp.Regexp
+	records   map[uint16][]dns.RR
+	windows   []*window
+	zone      string
+	soa       *dns.SOA
+	variants  []*variant
+	blocklist string
+	forwards  []*rule
+	nxdomain  bool
+
+	limitVariant *variant
+}
+
+func (r *rule) answer(question dns.Question, addr net.IP) ([]dns.RR, int) {
+	if r.nxdomain {
+		return nil, dns.RcodeNameError
+	}
+
+	if r.records != nil {
+		return r.recordsAnswer(question), dns.RcodeSuccess
+	}
+
+	if r.forwards != nil {
+		return r.reverseAnswer(question, addr), dns.RcodeSuccess
+	}
+
+	if r.config.Block != "" {
+		return makeBlockAnswer(question.Name, question.Qtype, r.config.Block, r.config.TTL)
+	}
+
+	if r.config.Synthesize {
+		answer, rcode := makeSynthesizedAnswer(question, r.zone, r.config.TTL)
+		if rcode == dns.RcodeNameError && r.config.Fallthrough {
+			return nil, dns.RcodeSuccess
+		}
+
+		return answer, rcode
+	}
+
+	variant := pickVariant(r.variants, addr, r.config.Sticky)
+	expand := r.expander(question.Name)
+
+	answer := variant.makeAnswer(question, r.config.TTL, expand)
+	if len(answer) == 0 && r.config.Flatten {
+		answer = variant.makeAlias(question, r.config.TTL, expand)
+	}
+
+	return answer, dns.RcodeSuccess
+}
+
+func (r *rule) expander(name string) func(string) string {
+	if r.regexp == nil {
+		return func(template string) string {
+			return template
+		}
+	}
+
+	match := r.regexp.FindStringSubmatchIndex(name)
+
+	return func(template string) string {
+		if match == nil || !strings.Contains(template, "$") {
+			return template
+		}
+
+		return string(r.regexp.ExpandString(nil, template, name, match))
+	}
+}
+
+func (r *rule) name() string {
+	if label := r.label(); label != "" {
+		return label
+	}
+
+	return r.config.Source
+}
+
+func (r *rule) label() string {
+	if r.config.Name != "" {
+		return r.config.Name
+	}
+
+	return r.blocklist
+}
+
+func (r *rule) authority(question dns.Question) dns.RR {
+	if r.soa != nil {
+		soa := dns.Copy(r.soa)
+		soa.Header().Ttl = min(r.soa.Hdr.Ttl, r.soa.Minttl)
+
+		return soa
+	}
+
+	zone := r.zone
+	if zone == "" {
+		zone = question.Name
+	}
+
+	return makeDNSAnswerSOA(zone, r.config.TTL)
+}
+
+func (r *rule) recordsAnswer(question dns.Question) []dns.RR {
+	records := r.records[question.Qtype]
+	if len(records) == 0 && question.Qtype != dns.TypeCNAME {
+		records = r.records[dns.TypeCNAME]
+	}
+
+	answers := make([]dns.RR, 0, len(records))
+
+	for _, record := range records {
+		answer := dns.Copy(record)
+		answer.Header().Name = question.Name
+
+		answers = append(answers, answer)
+	}
+
+	return answers
+}
+
+func (r *rule) active(now time.Time) bool {
+	if r.forwards != nil {
+		return slices.ContainsFunc(r.forwards, func(forward *rule) bool {
+			return forward.active(now)
+		})
+	}
+
+	if !r.config.ActiveFrom.IsZero() && now.Before(r.config.ActiveFrom) {
+		return false
+	}
+
+	if !r.config.ActiveUntil.IsZero() && !now.Before(r.config.ActiveUntil) {
+		return false
+	}
+
+	if len(r.windows) == 0 {
+		return true
+	}
+
+	for _, window := range r.windows {
+		if window.contains(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *rule) allowed(addr net.IP) bool {
+	if r.forwards != nil {
+		return slices.ContainsFunc(r.forwards, func(forward *rule) bool {
+			return forward.allowed(addr)
+		})
+	}
+
+	if len(r.config.Allow) == 0 && len(r.config.Deny) == 0 {
+		return true
+	}
+
+	clientAddr, ok := netip.AddrFromSlice(addr)
+	if !ok {
+		return false
+	}
+
+	clientAddr = clientAddr.Unmap()
+
+	for _, prefix := range r.config.Deny {
+		if prefix.Contains(clientAddr) {
+			return false
+		}
+	}
+
+	if len(r.config.Allow) == 0 {
+		return true
+	}
+
+	for _, prefix := range r.config.Allow {
+		if prefix.Contains(clientAddr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package dnsswitcher
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	clockLayout = "15:04"
+	endOfDay    = "24:00"
+)
+
+type windowConfig struct {
+	Days     []string `yaml:"days"`
+	From     string   `env-required:"true" yaml:"from"`
+	To       string   `env-required:"true" yaml:"to"`
+	Timezone string   `yaml:"timezone"`
+}
+
+type window struct {
+	days     map[time.Weekday]bool
+	from     time.Duration
+	to       time.Duration
+	location *time.Location
+}
+
+func newWindow(config *windowConfig) (*window, error) {
+	location := time.Local
+
+	if config.Timezone != "" {
+		var err error
+
+		location, err = time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't load timezone %q", config.Timezone)
+		}
+	}
+
+	from, err := parseClock(config.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to := 24 * time.Hour
+
+	if config.To != endOfDay {
+		to, err = parseClock(config.To)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if from == to {
+		return nil, errors.Errorf("window from %q to %q is empty", config.From, config.To)
+	}
+
+	days := make(map[time.Weekday]bool, len(config.Days))
+
+	for _, day := range config.Days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return nil, err
+		}
+
+		days[weekday] = true
+	}
+
+	return &window{
+		days:     days,
+		from:     from,
+		to:       to,
+		location: location,
+	}, nil
+}
+
+func (w *window) contains(now time.Time) bool {
+	now = now.In(w.location)
+
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	today := now.Weekday()
+	yesterday := now.AddDate(0, 0, -1).Weekday()
+
+	if w.from <= w.to {
+		return w.onDay(today) && clock >= w.from && clock < w.to
+	}
+
+	return (w.onDay(today) && clock >= w.from) || (w.onDay(yesterday) && clock < w.to)
+}
+
+func (w *window) onDay(day time.Weekday) bool {
+	return len(w.days) == 0 || w.days[day]
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse(clockLayout, value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't parse time of day %q", value)
+	}
+
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := day.String()
+
+		if strings.EqualFold(value, name) || strings.EqualFold(value, name[:3]) {
+			return day, nil
+		}
+	}
+
+	return 0, errors.Errorf("can't parse weekday %q", value)
+}
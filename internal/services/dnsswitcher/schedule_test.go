@@ -0,0 +1,54 @@
+package dnsswitcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWindowRejectsEmptyWindow(t *testing.T) {
+	if _, err := newWindow(&windowConfig{From: "00:00", To: "00:00"}); err == nil {
+		t.Fatal("expected error for window with equal bounds")
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	tests := []struct {
+		name   string
+		config windowConfig
+		now    string
+		want   bool
+	}{
+		{"inside", windowConfig{From: "09:00", To: "18:00"}, "2024-06-03T12:00:00Z", true},
+		{"at start", windowConfig{From: "09:00", To: "18:00"}, "2024-06-03T09:00:00Z", true},
+		{"at end", windowConfig{From: "09:00", To: "18:00"}, "2024-06-03T18:00:00Z", false},
+		{"whole day start", windowConfig{From: "00:00", To: "24:00"}, "2024-06-03T00:00:00Z", true},
+		{"whole day end", windowConfig{From: "00:00", To: "24:00"}, "2024-06-03T23:59:00Z", true},
+		{"until midnight", windowConfig{From: "22:00", To: "24:00"}, "2024-06-03T23:30:00Z", true},
+		{"overnight evening", windowConfig{From: "22:00", To: "06:00"}, "2024-06-03T23:00:00Z", true},
+		{"overnight morning", windowConfig{From: "22:00", To: "06:00"}, "2024-06-04T05:00:00Z", true},
+		{"overnight outside", windowConfig{From: "22:00", To: "06:00"}, "2024-06-04T12:00:00Z", false},
+		{"day matches", windowConfig{Days: []string{"mon"}, From: "09:00", To: "18:00"}, "2024-06-03T12:00:00Z", true},
+		{"day differs", windowConfig{Days: []string{"tue"}, From: "09:00", To: "18:00"}, "2024-06-03T12:00:00Z", false},
+		{"overnight previous day", windowConfig{Days: []string{"mon"}, From: "22:00", To: "06:00"}, "2024-06-04T05:00:00Z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Timezone = "UTC"
+
+			w, err := newWindow(&tt.config)
+			if err != nil {
+				t.Fatalf("newWindow() error = %v", err)
+			}
+
+			now, err := time.Parse(time.RFC3339, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := w.contains(now); got != tt.want {
+				t.Errorf("contains(%s) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package dnsswitcher
+
+import (
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	dashedIPv4Regexp = regexp.MustCompile(`(?:^|-)((?:\d{1,3}-){3}\d{1,3})$`)
+	hexIPv4Regexp    = regexp.MustCompile(`(?:^|-)([0-9a-f]{8})$`)
+	hexIPv6Regexp    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+func makeSynthesizedAnswer(question dns.Question, zone string, ttl uint32) ([]dns.RR, int) {
+	name := strings.ToLower(dns.Fqdn(question.Name))
+
+	labels := dns.SplitDomainName(strings.TrimSuffix(name, zone))
+	if len(labels) == 0 {
+		return nil, dns.RcodeSuccess
+	}
+
+	addr := parseEmbeddedAddr(labels)
+	if addr == nil {
+		return nil, dns.RcodeNameError
+	}
+
+	switch {
+	case addr.To4() != nil:
+		if question.Qtype == dns.TypeA {
+			return []dns.RR{makeDNSAnswerA(question.Name, addr.To4(), ttl)}, dns.RcodeSuccess
+		}
+
+	case question.Qtype == dns.TypeAAAA:
+		return []dns.RR{makeDNSAnswerAAAA(question.Name, addr, ttl)}, dns.RcodeSuccess
+	}
+
+	return nil, dns.RcodeSuccess
+}
+
+func parseEmbeddedAddr(labels []string) net.IP {
+	const ipv4Labels = 4
+
+	if len(labels) >= ipv4Labels {
+		dotted := strings.Join(labels[len(labels)-ipv4Labels:], ".")
+
+		if addr := net.ParseIP(dotted); addr != nil && addr.To4() != nil {
+			return addr
+		}
+	}
+
+	label := labels[len(labels)-1]
+
+	if match := dashedIPv4Regexp.FindStringSubmatch(label); match != nil && !hasNumericPrefix(label, match[1]) {
+		if addr := net.ParseIP(strings.ReplaceAll(match[1], "-", ".")); addr != nil {
+			return addr
+		}
+	}
+
+	if match := hexIPv4Regexp.FindStringSubmatch(label); match != nil {
+		if addr, err := hex.DecodeString(match[1]); err == nil {
+			return net.IP(addr)
+		}
+	}
+
+	if hexIPv6Regexp.MatchString(label) {
+		if addr, err := hex.DecodeString(label); err == nil {
+			return net.IP(addr)
+		}
+	}
+
+	if strings.Contains(label, "--") || strings.Count(label, "-") >= 7 {
+		if addr := net.ParseIP(strings.ReplaceAll(label, "-", ":")); addr != nil && addr.To4() == nil {
+			return addr
+		}
+	}
+
+	return nil
+}
+
+func hasNumericPrefix(label string, addr string) bool {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(label, addr), "-")
+	if prefix == "" {
+		return false
+	}
+
+	token := prefix[strings.LastIndexByte(prefix, '-')+1:]
+
+	return strings.Trim(token, "0123456789") == ""
+}
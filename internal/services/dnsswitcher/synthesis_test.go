@@ -0,0 +1,75 @@
+package dnsswitcher
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseEmbeddedAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+	}{
+		{name: "10.0.0.1", addr: "10.0.0.1"},
+		{name: "app.10.0.0.1", addr: "10.0.0.1"},
+		{name: "10-0-0-1", addr: "10.0.0.1"},
+		{name: "app-10-0-0-1", addr: "10.0.0.1"},
+		{name: "v2-10-0-0-1", addr: "10.0.0.1"},
+		{name: "0a000001", addr: "10.0.0.1"},
+		{name: "app-0a000001", addr: "10.0.0.1"},
+		{name: "20010db8000000000000000000000001", addr: "2001:db8::1"},
+		{name: "2001-db8--1", addr: "2001:db8::1"},
+		{name: "dead--beef", addr: "dead::beef"},
+		{name: "2001-db8-0-0-0-0-0-1", addr: "2001:db8::1"},
+		{name: "cafe-feed-1-2-3-4-5-6", addr: "cafe:feed:1:2:3:4:5:6"},
+		{name: "1-2-10-0-0-1", addr: ""},
+		{name: "app", addr: ""},
+		{name: "256-0-0-1", addr: ""},
+		{name: "app.0.0.1", addr: ""},
+		{name: "deadbeef1", addr: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := parseEmbeddedAddr(dns.SplitDomainName(tt.name))
+
+			if tt.addr == "" {
+				if addr != nil {
+					t.Fatalf("parseEmbeddedAddr() = %s, want nil", addr)
+				}
+
+				return
+			}
+
+			if !addr.Equal(net.ParseIP(tt.addr)) {
+				t.Fatalf("parseEmbeddedAddr() = %s, want %s", addr, tt.addr)
+			}
+		})
+	}
+}
+
+func TestSynthesizeFallthrough(t *testing.T) {
+	tests := []struct {
+		fall  bool
+		rcode int
+	}{
+		{fall: false, rcode: dns.RcodeNameError},
+		{fall: true, rcode: dns.RcodeSuccess},
+	}
+
+	for _, tt := range tests {
+		r := &rule{
+			config: &switchConfig{Source: ".nip.test", Synthesize: true, Fallthrough: tt.fall, TTL: 60},
+			zone:   "nip.test.",
+		}
+
+		question := dns.Question{Name: "app.nip.test.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+
+		answer, rcode := r.answer(question, nil)
+		if len(answer) != 0 || rcode != tt.rcode {
+			t.Errorf("answer(fallthrough=%t) = %v, %d, want no records and %d", tt.fall, answer, rcode, tt.rcode)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package dnsswitcher
+
+import (
+	"github.com/miekg/dns"
+)
+
+type trieNode struct {
+	children  map[string]*trieNode
+	suffixes  []*rule
+	wildcards []*rule
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children: make(map[string]*trieNode),
+	}
+}
+
+func (n *trieNode) insert(name string) *trieNode {
+	labels := dns.SplitDomainName(name)
+
+	node := n
+
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := node.children[labels[i]]
+		if !ok {
+			child = newTrieNode()
+			node.children[labels[i]] = child
+		}
+
+		node = child
+	}
+
+	return node
+}
+
+func (n *trieNode) insertSuffix(name string, rule *rule) {
+	node := n.insert(name)
+	node.suffixes = append(node.suffixes, rule)
+}
+
+func (n *trieNode) insertWildcard(name string, rule *rule) {
+	node := n.insert(name)
+	node.wildcards = append(node.wildcards, rule)
+}
+
+func (n *trieNode) find(name string, accept func(r *rule) bool) *rule {
+	labels := dns.SplitDomainName(name)
+
+	path := make([]*trieNode, 0, len(labels)+1)
+	path = append(path, n)
+
+	node := n
+
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := node.children[labels[i]]
+		if !ok {
+			break
+		}
+
+		path = append(path, child)
+		node = child
+	}
+
+	for depth := len(path) - 1; depth >= 0; depth-- {
+		node := path[depth]
+
+		if depth < len(labels) {
+			for _, rule := range node.wildcards {
+				if accept(rule) {
+					return rule
+				}
+			}
+		}
+
+		for _, rule := range node.suffixes {
+			if accept(rule) {
+				return rule
+			}
+		}
+	}
+
+	return nil
+}
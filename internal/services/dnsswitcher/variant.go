@@ -0,0 +1,192 @@
+package dnsswitcher
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	orderFixed      = "fixed"
+	orderShuffle    = "shuffle"
+	orderRoundRobin = "round-robin"
+)
+
+type variantConfig struct {
+	Weight      uint32     `env-required:"true" yaml:"weight"`
+	Destination string     `yaml:"destination"`
+	Answer      *dnsAnswer `yaml:"answer"`
+}
+
+type variant struct {
+	weight      uint32
+	destination string
+	answer      *dnsAnswer
+
+	counter atomic.Uint64
+}
+
+func newVariants(config *switchConfig) ([]*variant, error) {
+	if len(config.Variants) == 0 {
+		single, err := newVariant(1, config.Destination, config.Answer)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*variant{single}, nil
+	}
+
+	variants := make([]*variant, 0, len(config.Variants))
+
+	for i := range config.Variants {
+		variant, err := newVariant(config.Variants[i].Weight, config.Variants[i].Destination, config.Variants[i].Answer)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+func newVariant(weight uint32, destination string, answer *dnsAnswer) (*variant, error) {
+	if weight == 0 {
+		return nil, errors.New("weight is not set")
+	}
+
+	if destination == "" && answer == nil {
+		return nil, errors.New("destination or answer is not set")
+	}
+
+	if answer != nil && !isValidOrder(answer.Order) {
+		return nil, errors.Errorf("answer order %q is not supported", answer.Order)
+	}
+
+	return &variant{
+		weight:      weight,
+		destination: destination,
+		answer:      answer,
+	}, nil
+}
+
+func (v *variant) makeAnswer(question dns.Question, ttl uint32, expand func(string) string) []dns.RR {
+	if v.destination != "" {
+		return parseDNSAnswer(question.Name, question.Qtype, expand(v.destination), ttl)
+	}
+
+	return v.order(makeDNSAnswer(question.Name, question.Qtype, v.answer, ttl, expand))
+}
+
+func (v *variant) makeAlias(question dns.Question, ttl uint32, expand func(string) string) []dns.RR {
+	if v.answer == nil || v.answer.CNAME == "" || question.Qtype == dns.TypeCNAME {
+		return nil
+	}
+
+	return []dns.RR{makeDNSAnswerCNAME(question.Name, expand(v.answer.CNAME), ttl)}
+}
+
+func (v *variant) order(answers []dns.RR) []dns.RR {
+	if len(answers) == 0 {
+		return answers
+	}
+
+	switch v.answer.Order {
+	case orderShuffle:
+		rand.Shuffle(len(answers), func(i, j int) {
+			answers[i], answers[j] = answers[j], answers[i]
+		})
+
+	case orderRoundRobin:
+		offset := int((v.counter.Add(1) - 1) % uint64(len(answers)))
+
+		rotated := make([]dns.RR, 0, len(answers))
+		rotated = append(rotated, answers[offset:]...)
+		rotated = append(rotated, answers[:offset]...)
+
+		answers = rotated
+	}
+
+	return answers
+}
+
+func pickVariant(variants []*variant, addr net.IP, sticky bool) *variant {
+	if len(variants) == 1 {
+		return variants[0]
+	}
+
+	if sticky {
+		return pickStickyVariant(variants, addr)
+	}
+
+	var total uint64
+
+	for _, variant := range variants {
+		total += uint64(variant.weight)
+	}
+
+	n := rand.Uint64N(total)
+
+	for _, variant := range variants {
+		if n < uint64(variant.weight) {
+			return variant
+		}
+
+		n -= uint64(variant.weight)
+	}
+
+	return variants[len(variants)-1]
+}
+
+func pickStickyVariant(variants []*variant, addr net.IP) *variant {
+	const mantissaBits = 53
+
+	var (
+		best      *variant
+		bestScore = math.Inf(-1)
+	)
+
+	key, _ := netip.AddrFromSlice(addr)
+	key = key.Unmap()
+
+	for i, variant := range variants {
+		hash := fnv.New64a()
+		_, _ = hash.Write(key.AsSlice())
+		_ = binary.Write(hash, binary.BigEndian, uint32(i))
+
+		u := (float64(mix(hash.Sum64())>>(64-mantissaBits)) + 0.5) / (1 << mantissaBits)
+		score := -float64(variant.weight) / math.Log(u)
+
+		if score > bestScore {
+			best, bestScore = variant, score
+		}
+	}
+
+	return best
+}
+
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+func isValidOrder(order string) bool {
+	switch order {
+	case "", orderFixed, orderShuffle, orderRoundRobin:
+		return true
+	}
+
+	return false
+}
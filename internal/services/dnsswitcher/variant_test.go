@@ -0,0 +1,21 @@
+package dnsswitcher
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPickStickyVariantCanonicalAddr(t *testing.T) {
+	variants := []*variant{{weight: 1}, {weight: 1}, {weight: 1}, {weight: 1}}
+
+	for i := range 256 {
+		addr := net.IPv4(192, 0, 2, byte(i))
+
+		short := pickStickyVariant(variants, addr.To4())
+		long := pickStickyVariant(variants, addr.To16())
+
+		if short != long {
+			t.Fatalf("address %s picks different variants for 4 and 16 byte forms", addr)
+		}
+	}
+}
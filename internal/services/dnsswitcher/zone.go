@@ -0,0 +1,96 @@
+package dnsswitcher
+
+import (
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+func loadZone(config *zoneConfig) (map[string][]dns.RR, error) {
+	file, err := os.Open(config.Path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open zone file %q", config.Path)
+	}
+
+	defer file.Close()
+
+	var origin string
+
+	if config.Origin != "" {
+		origin = dns.Fqdn(config.Origin)
+	}
+
+	parser := dns.NewZoneParser(file, origin, config.Path)
+	parser.SetIncludeAllowed(true)
+
+	records := make(map[string][]dns.RR)
+
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		name := strings.ToLower(rr.Header().Name)
+
+		records[name] = append(records[name], rr)
+	}
+
+	if err := parser.Err(); err != nil {
+		return nil, errors.Wrapf(err, "can't parse zone file %q", config.Path)
+	}
+
+	return records, nil
+}
+
+func zoneSOA(records map[string][]dns.RR) *dns.SOA {
+	for _, rrs := range records {
+		for _, rr := range rrs {
+			if soa, ok := rr.(*dns.SOA); ok {
+				return soa
+			}
+		}
+	}
+
+	return nil
+}
+
+func emptyNonTerminals(records map[string][]dns.RR, origin string) []string {
+	seen := make(map[string]bool)
+
+	var names []string
+
+	for name := range records {
+		if !dns.IsSubDomain(origin, name) {
+			continue
+		}
+
+		for parent := name; parent != origin; {
+			offset, end := dns.NextLabel(parent, 0)
+			if end {
+				break
+			}
+
+			parent = parent[offset:]
+
+			if _, ok := records[parent]; ok || seen[parent] || parent == origin {
+				continue
+			}
+
+			seen[parent] = true
+
+			names = append(names, parent)
+		}
+	}
+
+	return names
+}
+
+func groupRecords(rrs []dns.RR) map[uint16][]dns.RR {
+	records := make(map[uint16][]dns.RR)
+
+	for _, rr := range rrs {
+		rrtype := rr.Header().Rrtype
+
+		records[rrtype] = append(records[rrtype], rr)
+	}
+
+	return records
+}
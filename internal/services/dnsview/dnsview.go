@@ -0,0 +1,181 @@
+package dnsview
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"masquerade-dns/internal/metrics"
+	"masquerade-dns/internal/pkg/subnet"
+	"masquerade-dns/internal/services/dnsresolver"
+	"masquerade-dns/internal/services/dnsswitcher"
+)
+
+const defaultView = "default"
+
+type dnsLimiter interface {
+	Limit(addr net.IP, source string, maxCount int, prefix subnet.Config) bool
+}
+
+type Resolver interface {
+	Lookup(ctx context.Context, req *dns.Msg) *dns.Msg
+}
+
+type Switcher interface {
+	Switch(ctx context.Context, addr net.IP, req *dns.Msg) (*dns.Msg, bool)
+}
+
+type viewConfig struct {
+	Name      string              `env-required:"true" yaml:"name"`
+	Listeners []string            `yaml:"listeners"`
+	Clients   []netip.Prefix      `yaml:"clients"`
+	Switcher  *dnsswitcher.Config `yaml:"switcher"`
+	Resolver  *dnsresolver.Config `yaml:"resolver"`
+}
+
+type Config struct {
+	Settings []viewConfig `yaml:"settings"`
+}
+
+type View struct {
+	Name     string
+	Resolver Resolver
+	Switcher Switcher
+
+	listeners map[string]bool
+	clients   []netip.Prefix
+}
+
+type Service struct {
+	config *Config
+
+	views       []*View
+	defaultView *View
+}
+
+func NewService(
+	config *Config,
+	switcherConfig *dnsswitcher.Config,
+	metrics *metrics.Metrics,
+	limiter dnsLimiter,
+	resolver Resolver,
+	switcher Switcher,
+	logger *zap.SugaredLogger,
+) (*Service, error) {
+	views := make([]*View, 0, len(config.Settings))
+
+	for i := range config.Settings {
+		view, err := newView(&config.Settings[i], switcherConfig, metrics, limiter, resolver, switcher, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't create view %q", config.Settings[i].Name)
+		}
+
+		views = append(views, view)
+	}
+
+	return &Service{
+		config: config,
+		views:  views,
+		defaultView: &View{
+			Name:     defaultView,
+			Resolver: resolver,
+			Switcher: switcher,
+		},
+	}, nil
+}
+
+func (s *Service) Select(listener string, addr net.IP) *View {
+	clientAddr, ok := netip.AddrFromSlice(addr)
+	if ok {
+		clientAddr = clientAddr.Unmap()
+	}
+
+	for _, view := range s.views {
+		if view.match(listener, clientAddr) {
+			return view
+		}
+	}
+
+	return s.defaultView
+}
+
+func newView(
+	config *viewConfig,
+	switcherConfig *dnsswitcher.Config,
+	metrics *metrics.Metrics,
+	limiter dnsLimiter,
+	resolver Resolver,
+	switcher Switcher,
+	logger *zap.SugaredLogger,
+) (*View, error) {
+	if len(config.Listeners) == 0 && len(config.Clients) == 0 {
+		return nil, errors.New("listeners or clients are not set")
+	}
+
+	listeners := make(map[string]bool, len(config.Listeners))
+
+	for _, listener := range config.Listeners {
+		host, port, err := net.SplitHostPort(listener)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't parse listener %q", listener)
+		}
+
+		listeners[net.JoinHostPort(host, port)] = true
+	}
+
+	for _, prefix := range config.Clients {
+		if !prefix.IsValid() {
+			return nil, errors.New("client prefix is not valid")
+		}
+	}
+
+	logger = logger.With("view", config.Name)
+
+	if config.Resolver != nil {
+		viewResolver, err := dnsresolver.NewService(config.Resolver, metrics, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't create DNS resolver")
+		}
+
+		resolver = viewResolver
+	}
+
+	if config.Switcher != nil {
+		switcherConfig = config.Switcher
+	}
+
+	if config.Switcher != nil || config.Resolver != nil {
+		viewSwitcher, err := dnsswitcher.NewService(switcherConfig, metrics, limiter, resolver, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't create DNS switcher")
+		}
+
+		switcher = viewSwitcher
+	}
+
+	return &View{
+		Name:      config.Name,
+		Resolver:  resolver,
+		Switcher:  switcher,
+		listeners: listeners,
+		clients:   config.Clients,
+	}, nil
+}
+
+func (v *View) match(listener string, addr netip.Addr) bool {
+	if v.listeners[listener] {
+		return true
+	}
+
+	for _, prefix := range v.clients {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package dnsview
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"masquerade-dns/internal/metrics"
+	"masquerade-dns/internal/pkg/subnet"
+	"masquerade-dns/internal/services/dnsswitcher"
+)
+
+var testMetrics = metrics.NewMetrics()
+
+type stubLimiter struct{}
+
+func (stubLimiter) Limit(net.IP, string, int, subnet.Config) bool {
+	return false
+}
+
+type stubResolver struct{}
+
+func (stubResolver) Lookup(_ context.Context, req *dns.Msg) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetReply(req)
+
+	return resp
+}
+
+type stubSwitcher struct{}
+
+func (stubSwitcher) Switch(context.Context, net.IP, *dns.Msg) (*dns.Msg, bool) {
+	return nil, false
+}
+
+func newTestService(t *testing.T, config *Config) *Service {
+	t.Helper()
+
+	service, err := NewService(
+		config,
+		&dnsswitcher.Config{},
+		testMetrics,
+		stubLimiter{},
+		stubResolver{},
+		stubSwitcher{},
+		zap.NewNop().Sugar(),
+	)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	return service
+}
+
+func TestSelect(t *testing.T) {
+	service := newTestService(t, &Config{
+		Settings: []viewConfig{
+			{
+				Name:      "internal",
+				Listeners: []string{"127.0.0.1:5300"},
+				Clients:   []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			},
+		},
+	})
+
+	tests := []struct {
+		listener string
+		addr     net.IP
+		want     string
+	}{
+		{"127.0.0.1:5300", net.IPv4(192, 0, 2, 1), "internal"},
+		{"0.0.0.0:53", net.IPv4(10, 1, 2, 3), "internal"},
+		{"127.0.0.1:5300", net.IPv4(10, 1, 2, 3), "internal"},
+		{"0.0.0.0:53", net.IPv4(192, 0, 2, 1), defaultView},
+	}
+
+	for _, tt := range tests {
+		if got := service.Select(tt.listener, tt.addr).Name; got != tt.want {
+			t.Errorf("Select(%q, %s) = %q, want %q", tt.listener, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestResolverOnlyViewSwitcher(t *testing.T) {
+	var config Config
+
+	err := yaml.Unmarshal([]byte(`
+settings:
+  - name: branch
+    clients:
+      - 172.16.0.0/12
+    resolver:
+      timeout: 1s
+      mode: random
+      nameservers:
+        - address: 192.0.2.53:53
+`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := newTestService(t, &config)
+
+	view := service.Select("0.0.0.0:53", net.IPv4(172, 16, 0, 1))
+	if view.Name != "branch" {
+		t.Fatalf("Select() = %q, want %q", view.Name, "branch")
+	}
+
+	if _, ok := view.Switcher.(*dnsswitcher.Service); !ok {
+		t.Fatalf("view switcher is %T, want a switcher bound to the view resolver", view.Switcher)
+	}
+}